@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bmstu-dips-lab1/config"
+	h "bmstu-dips-lab1/internal/person/delivery/http"
+	"bmstu-dips-lab1/internal/person/repo"
+	"bmstu-dips-lab1/internal/person/usecase"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"bmstu-dips-lab1/pkg/postgres"
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// jsonLinesSource reads one person per line, the same object as POST /api/v1/persons accepts
+type jsonLinesSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *jsonLinesSource) Next() (*models.PersonRow, error) {
+	for s.scanner.Scan() {
+		s.line++
+
+		text := strings.TrimSpace(s.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := &models.PersonRow{Line: s.line}
+		request := new(h.PersonCreatRequest)
+
+		row.Err = json.Unmarshal([]byte(text), request)
+		if row.Err != nil {
+			row.Err = errs.FromBinding(row.Err)
+		} else {
+			row.Person = h.PersonCreatRequestToBL(request)
+		}
+
+		return row, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+func main() {
+	file := flag.String("file", "", "JSON lines file to import, stdin when empty")
+	flag.Parse()
+
+	input := os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Open: %v", err)
+		}
+		defer f.Close()
+
+		input = f
+	}
+
+	cfgFile, err := config.LoadConfig("./config/config")
+	if err != nil {
+		log.Fatalf("LoadConfig: %v", err)
+	}
+
+	cfg, err := config.ParseConfig(cfgFile)
+	if err != nil {
+		log.Fatalf("ParseConfig: %v", err)
+	}
+
+	psqlDB, err := postgres.New(cfg)
+	if err != nil {
+		log.Fatalf("Postgresql init: %s", err)
+	}
+	defer psqlDB.Close()
+
+	pUC := usecase.NewPersonUseCase(cfg, repo.NewPersonRepo(psqlDB))
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	report, err := pUC.Import(context.Background(), &jsonLinesSource{scanner: scanner})
+	if err != nil {
+		log.Fatalf("Import: %v", err)
+	}
+
+	for _, row := range report.Rejected {
+		log.Printf("line %d rejected: %v", row.Line, row.Err)
+	}
+
+	log.Printf("Imported %d persons in %s (%.0f rows/s), rejected %d",
+		report.Imported, report.Duration, report.RowsPerSecond(), len(report.Rejected))
+}
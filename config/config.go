@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,18 +13,35 @@ import (
 
 // App config struct
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
+	Server     ServerConfig
+	Postgres   PostgresConfig
+	Persons    PersonsConfig
+	Users      UsersConfig
+	Search     SearchConfig
+	Validation ValidationConfig
 	// Cors     CorsConfig
 }
 
+// Anonymous access to the API, a request without a bearer token gets
+// nothing, only the safe methods or everything. The trash and the history
+// of persons are read anonymously only with full access.
+const (
+	AnonymousAccessNone = "none"
+	AnonymousAccessRead = "read"
+	AnonymousAccessFull = "full"
+)
+
 type ServerConfig struct {
-	AppVersion   string
-	Port         string
+	AppVersion string
+	Port       string
+	// HS256 tokens are accepted when set
 	JwtSecretKey string
-	CtxUserKey   string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	// PEM file of the RSA key, RS256 tokens are accepted when set
+	JwtPublicKeyFile string
+	AnonymousAccess  string
+	CtxUserKey       string
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
 }
 
 type PostgresConfig struct {
@@ -32,6 +52,46 @@ type PostgresConfig struct {
 	PostgresqlDbname   string
 }
 
+type PersonsConfig struct {
+	// PUT of a missing id creates the person instead of 404
+	UpsertOnPut bool
+	// PATCH, PUT and DELETE without If-Match are rejected with 428
+	RequireIfMatch bool
+	// how long a POST with an Idempotency-Key is replayed
+	IdempotencyTTL time.Duration
+}
+
+type UsersConfig struct {
+	// register, login and refresh are served, server.jwtsecretkey has to be
+	// set then
+	Enabled         bool
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// bcrypt cost of the password hashes
+	PasswordCost int
+}
+
+type SearchConfig struct {
+	FuzzyThreshold float32
+}
+
+// Person fields rules, defaults keep what the persons_ table can store
+type ValidationConfig struct {
+	Name    FieldRule
+	Address FieldRule
+	Work    FieldRule
+	Age     FieldRule
+}
+
+// Min and Max limit the length of a string or the value of age, Regex
+// applies to strings only
+type FieldRule struct {
+	Required bool
+	Min      *int
+	Max      *int
+	Regex    string
+}
+
 // type CorsConfig struct {
 // 	AllowOrigins []string
 // 	AllowMethods []string
@@ -43,6 +103,17 @@ func LoadConfig(filename string) (*viper.Viper, error) {
 
 	v.SetConfigName(filename)
 	v.AddConfigPath(".")
+	// any key can be overridden from the environment: persons.requireifmatch
+	// is PERSONS_REQUIREIFMATCH
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	// keys that are neither in the file nor defaulted are read only when bound,
+	// the JWT keys are kept out of the file
+	for _, key := range []string{"server.jwtsecretkey", "server.jwtpublickeyfile"} {
+		if err := v.BindEnv(key); err != nil {
+			return nil, err
+		}
+	}
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			return nil, errors.New("config file not found")
@@ -56,11 +127,66 @@ func LoadConfig(filename string) (*viper.Viper, error) {
 func ParseConfig(v *viper.Viper) (*Config, error) {
 	var c Config
 
+	v.SetDefault("server.ctxuserkey", "user")
+	v.SetDefault("server.anonymousaccess", AnonymousAccessNone)
+	v.SetDefault("persons.requireifmatch", true)
+	v.SetDefault("persons.idempotencyttl", "24h")
+	v.SetDefault("search.fuzzythreshold", 0.3)
+	v.SetDefault("users.enabled", false)
+	v.SetDefault("users.accesstokenttl", "15m")
+	v.SetDefault("users.refreshtokenttl", "720h")
+	v.SetDefault("users.passwordcost", 10)
+
+	for _, field := range []string{"name", "address", "work"} {
+		v.SetDefault("validation."+field+".required", true)
+		v.SetDefault("validation."+field+".max", 64)
+	}
+	v.SetDefault("validation.age.required", true)
+	v.SetDefault("validation.age.min", 0)
+	v.SetDefault("validation.age.max", 150)
+
 	err := v.Unmarshal(&c)
 	if err != nil {
 		log.Printf("unable to decode into struct, %v", err)
 		return nil, err
 	}
 
+	err = c.Server.check()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Validation.check()
+	if err != nil {
+		return nil, err
+	}
+
 	return &c, nil
 }
+
+func (c *ServerConfig) check() error {
+	switch c.AnonymousAccess {
+	case AnonymousAccessNone, AnonymousAccessRead, AnonymousAccessFull:
+	default:
+		return fmt.Errorf("server.anonymousaccess: unknown access %q", c.AnonymousAccess)
+	}
+
+	return nil
+}
+
+func (c *ValidationConfig) check() error {
+	rules := map[string]FieldRule{"name": c.Name, "address": c.Address, "work": c.Work, "age": c.Age}
+
+	for field, rule := range rules {
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return fmt.Errorf("validation.%s: min is greater than max", field)
+		}
+
+		_, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return fmt.Errorf("validation.%s: %w", field, err)
+		}
+	}
+
+	return nil
+}
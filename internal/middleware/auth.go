@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const bearerScheme = "Bearer"
+
+type MiddlewareManager struct {
+	cfg       *config.Config
+	publicKey *rsa.PublicKey
+}
+
+func NewMiddlewareManager(cfg *config.Config) (*MiddlewareManager, error) {
+	mw := &MiddlewareManager{cfg: cfg}
+
+	if cfg.Server.JwtPublicKeyFile != "" {
+		pem, err := os.ReadFile(cfg.Server.JwtPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		mw.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("server.jwtpublickeyfile: %w", err)
+		}
+	}
+
+	return mw, nil
+}
+
+// AuthJWTMiddleware puts the claims of the bearer token into the context
+// under the configured key. A request without a token goes through as
+// anonymous when the config allows it, a token that does not hold is
+// always 401.
+func (mw *MiddlewareManager) AuthJWTMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			if mw.anonymous(c.Request.Method) {
+				c.Next()
+				return
+			}
+
+			c.Header("WWW-Authenticate", bearerScheme)
+			errs.Abort(c, errs.ErrUnauthorized)
+			return
+		}
+
+		claims, err := mw.parseToken(header)
+		if err != nil {
+			c.Header("WWW-Authenticate", bearerScheme+` error="invalid_token"`)
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Set(mw.cfg.Server.CtxUserKey, claims)
+		c.Next()
+	}
+}
+
+// UserRequiredMiddleware keeps reads which reveal more than the current
+// persons, like the trash and the history, behind a token even when the
+// config lets anonymous requests read. It goes after AuthJWTMiddleware.
+func (mw *MiddlewareManager) UserRequiredMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(mw.cfg.Server.CtxUserKey); ok || mw.cfg.Server.AnonymousAccess == config.AnonymousAccessFull {
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", bearerScheme)
+		errs.Abort(c, errs.ErrUnauthorized)
+	}
+}
+
+// anonymous tells whether a request of method may go without a token.
+func (mw *MiddlewareManager) anonymous(method string) bool {
+	switch mw.cfg.Server.AnonymousAccess {
+	case config.AnonymousAccessFull:
+		return true
+	case config.AnonymousAccessRead:
+		return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+	}
+
+	return false
+}
+
+func (mw *MiddlewareManager) parseToken(header string) (*models.UserClaims, error) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, bearerScheme) || token == "" {
+		return nil, fmt.Errorf("%w: bearer token expected", errs.ErrInvalidAccessToken)
+	}
+
+	claims := &models.UserClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, mw.key,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidAccessToken, err)
+	}
+
+	if claims.TokenType == models.TokenTypeRefresh {
+		return nil, fmt.Errorf("%w: refresh token is not an access token", errs.ErrInvalidAccessToken)
+	}
+
+	if claims.String() == "" {
+		return nil, fmt.Errorf("%w: token has no subject", errs.ErrInvalidAccessToken)
+	}
+
+	return claims, nil
+}
+
+// key is the key token is verified with, only the algorithms a key is
+// configured for are accepted.
+func (mw *MiddlewareManager) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if mw.cfg.Server.JwtSecretKey != "" {
+			return []byte(mw.cfg.Server.JwtSecretKey), nil
+		}
+	case *jwt.SigningMethodRSA:
+		if mw.publicKey != nil {
+			return mw.publicKey, nil
+		}
+	}
+
+	return nil, fmt.Errorf("signing method %s is not accepted", token.Method.Alg())
+}
@@ -0,0 +1,334 @@
+package middleware_test
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/internal/middleware"
+	"bmstu-dips-lab1/models"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	_secret     = "secret"
+	_ctxUserKey = "user"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims *models.UserClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + token
+}
+
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestAuthJWTMiddleware(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyFile := writePublicKey(t, rsaKey)
+
+	inHour := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	access := &models.UserClaims{
+		TokenType:        models.TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "7", ExpiresAt: inHour},
+	}
+
+	hs256 := signToken(t, jwt.SigningMethodHS256, []byte(_secret), access)
+	rs256 := signToken(t, jwt.SigningMethodRS256, rsaKey, access)
+
+	type keys int
+	const (
+		secretOnly keys = iota
+		publicKeyOnly
+		both
+	)
+
+	testTable := []struct {
+		nameTest          string
+		anonymousAccess   string
+		keys              keys
+		method            string
+		authorization     string
+		expectedStatus    int
+		expectedChallenge string
+		expectedUser      string
+	}{
+		{
+			nameTest:          "none_missing",
+			anonymousAccess:   config.AnonymousAccessNone,
+			method:            http.MethodGet,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: "Bearer",
+		},
+		{
+			nameTest:        "read_missing_get",
+			anonymousAccess: config.AnonymousAccessRead,
+			method:          http.MethodGet,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			nameTest:          "read_missing_post",
+			anonymousAccess:   config.AnonymousAccessRead,
+			method:            http.MethodPost,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: "Bearer",
+		},
+		{
+			nameTest:        "full_missing",
+			anonymousAccess: config.AnonymousAccessFull,
+			method:          http.MethodDelete,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			nameTest:          "wrong_scheme",
+			anonymousAccess:   config.AnonymousAccessFull,
+			method:            http.MethodGet,
+			authorization:     "Basic dXNlcjpwYXNz",
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			nameTest:          "hs256_without_secret",
+			anonymousAccess:   config.AnonymousAccessNone,
+			keys:              publicKeyOnly,
+			method:            http.MethodGet,
+			authorization:     hs256,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			nameTest:          "rs256_without_public_key",
+			anonymousAccess:   config.AnonymousAccessNone,
+			keys:              secretOnly,
+			method:            http.MethodGet,
+			authorization:     rs256,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			nameTest:        "hs256",
+			anonymousAccess: config.AnonymousAccessNone,
+			keys:            both,
+			method:          http.MethodPost,
+			authorization:   hs256,
+			expectedStatus:  http.StatusOK,
+			expectedUser:    "7",
+		},
+		{
+			nameTest:        "rs256",
+			anonymousAccess: config.AnonymousAccessNone,
+			keys:            both,
+			method:          http.MethodPost,
+			authorization:   rs256,
+			expectedStatus:  http.StatusOK,
+			expectedUser:    "7",
+		},
+		{
+			nameTest:        "login",
+			anonymousAccess: config.AnonymousAccessNone,
+			method:          http.MethodGet,
+			authorization: signToken(t, jwt.SigningMethodHS256, []byte(_secret), &models.UserClaims{
+				Login:            "ivan",
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "7", ExpiresAt: inHour},
+			}),
+			expectedStatus: http.StatusOK,
+			expectedUser:   "ivan",
+		},
+		{
+			nameTest:        "expired",
+			anonymousAccess: config.AnonymousAccessNone,
+			method:          http.MethodGet,
+			authorization: signToken(t, jwt.SigningMethodHS256, []byte(_secret), &models.UserClaims{
+				TokenType: models.TokenTypeAccess,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "7",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+				},
+			}),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			nameTest:        "no_exp",
+			anonymousAccess: config.AnonymousAccessNone,
+			method:          http.MethodGet,
+			authorization: signToken(t, jwt.SigningMethodHS256, []byte(_secret), &models.UserClaims{
+				TokenType:        models.TokenTypeAccess,
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "7"},
+			}),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			nameTest:        "refresh",
+			anonymousAccess: config.AnonymousAccessNone,
+			method:          http.MethodGet,
+			authorization: signToken(t, jwt.SigningMethodHS256, []byte(_secret), &models.UserClaims{
+				TokenType:        models.TokenTypeRefresh,
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "7", ExpiresAt: inHour},
+			}),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			nameTest:        "no_subject",
+			anonymousAccess: config.AnonymousAccessNone,
+			method:          http.MethodGet,
+			authorization: signToken(t, jwt.SigningMethodHS256, []byte(_secret), &models.UserClaims{
+				TokenType:        models.TokenTypeAccess,
+				RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: inHour},
+			}),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="invalid_token"`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{Server: config.ServerConfig{
+				AnonymousAccess: testCase.anonymousAccess,
+				CtxUserKey:      _ctxUserKey,
+			}}
+			if testCase.keys != publicKeyOnly {
+				cfg.Server.JwtSecretKey = _secret
+			}
+			if testCase.keys != secretOnly {
+				cfg.Server.JwtPublicKeyFile = publicKeyFile
+			}
+
+			mw, err := middleware.NewMiddlewareManager(cfg)
+			assert.NoError(t, err)
+
+			user := ""
+			r := gin.New()
+			r.Use(mw.AuthJWTMiddleware())
+			r.Any("/persons", func(c *gin.Context) {
+				if claims, ok := c.Get(_ctxUserKey); ok {
+					user = claims.(*models.UserClaims).String()
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(testCase.method, "/persons", nil)
+			if testCase.authorization != "" {
+				req.Header.Set("Authorization", testCase.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatus, w.Code)
+			assert.Equal(t, testCase.expectedChallenge, w.Header().Get("WWW-Authenticate"))
+			assert.Equal(t, testCase.expectedUser, user)
+		})
+	}
+}
+
+func TestUserRequiredMiddleware(t *testing.T) {
+	t.Parallel()
+
+	gin.SetMode(gin.TestMode)
+
+	token := signToken(t, jwt.SigningMethodHS256, []byte(_secret), &models.UserClaims{
+		TokenType: models.TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "7",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+
+	testTable := []struct {
+		nameTest        string
+		anonymousAccess string
+		authorization   string
+		expectedStatus  int
+	}{
+		{
+			nameTest:        "read_missing",
+			anonymousAccess: config.AnonymousAccessRead,
+			expectedStatus:  http.StatusUnauthorized,
+		},
+		{
+			nameTest:        "read_token",
+			anonymousAccess: config.AnonymousAccessRead,
+			authorization:   token,
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			nameTest:        "full_missing",
+			anonymousAccess: config.AnonymousAccessFull,
+			expectedStatus:  http.StatusOK,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			t.Parallel()
+
+			mw, err := middleware.NewMiddlewareManager(&config.Config{Server: config.ServerConfig{
+				JwtSecretKey:    _secret,
+				AnonymousAccess: testCase.anonymousAccess,
+				CtxUserKey:      _ctxUserKey,
+			}})
+			assert.NoError(t, err)
+
+			r := gin.New()
+			r.Use(mw.AuthJWTMiddleware())
+			r.GET("/persons/trash", mw.UserRequiredMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/persons/trash", nil)
+			if testCase.authorization != "" {
+				req.Header.Set("Authorization", testCase.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatus, w.Code)
+		})
+	}
+}
@@ -4,8 +4,18 @@ import "github.com/gin-gonic/gin"
 
 type Handlers interface {
 	Create() gin.HandlerFunc
+	CreateMany() gin.HandlerFunc
 	Delete() gin.HandlerFunc
+	Trash() gin.HandlerFunc
+	Restore() gin.HandlerFunc
+	Purge() gin.HandlerFunc
+	History() gin.HandlerFunc
+	Revert() gin.HandlerFunc
 	Update() gin.HandlerFunc
+	Replace() gin.HandlerFunc
 	GetById() gin.HandlerFunc
 	GetAll() gin.HandlerFunc
+	ExportCSV() gin.HandlerFunc
+	Import() gin.HandlerFunc
+	Search() gin.HandlerFunc
 }
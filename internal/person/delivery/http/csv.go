@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var csvColumns = []string{"id", "name", "address", "work", "age"}
+
+// streamFlushEvery bounds the rows buffered before they reach the client
+const streamFlushEvery = 1000
+
+type PersonCSVWriter struct {
+	w       *csv.Writer
+	flusher interface{ Flush() }
+	written int
+}
+
+func NewPersonCSVWriter(w io.Writer, flusher interface{ Flush() }) (*PersonCSVWriter, error) {
+	res := &PersonCSVWriter{w: csv.NewWriter(w), flusher: flusher}
+
+	err := res.w.Write(csvColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (p *PersonCSVWriter) Write(modelBL *models.Person) error {
+	err := p.w.Write([]string{
+		strconv.Itoa(modelBL.Id),
+		modelBL.Name,
+		modelBL.Address,
+		modelBL.Work,
+		strconv.Itoa(modelBL.Age),
+	})
+	if err != nil {
+		return err
+	}
+
+	p.written++
+	if p.written%streamFlushEvery == 0 {
+		return p.Flush()
+	}
+
+	return nil
+}
+
+func (p *PersonCSVWriter) Flush() error {
+	p.w.Flush()
+	p.flusher.Flush()
+
+	return p.w.Error()
+}
+
+// personCSVSource reads persons from CSV with a header row which maps
+// columns onto name, address, work and age in any order. An id column is
+// allowed and ignored, so an export can be imported back.
+type personCSVSource struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func NewPersonCSVSource(r io.Reader) (models.PersonSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errs.Invalid("csv header is missing or malformed")
+	}
+
+	columns := make(map[string]int, len(header))
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if !contains(csvColumns, name) {
+			return nil, errs.Invalid("unknown csv column %q", name)
+		}
+
+		if _, ok := columns[name]; ok {
+			return nil, errs.Invalid("csv column %q is repeated", name)
+		}
+
+		columns[name] = i
+	}
+
+	for _, name := range csvColumns[1:] {
+		if _, ok := columns[name]; !ok {
+			return nil, errs.Invalid("csv column %q is missing", name)
+		}
+	}
+
+	return &personCSVSource{r: reader, columns: columns}, nil
+}
+
+func (s *personCSVSource) Next() (*models.PersonRow, error) {
+	record, err := s.r.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &models.PersonRow{Line: parseErr.StartLine, Err: parseErr.Err}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := s.r.FieldPos(0)
+	row := &models.PersonRow{Line: line}
+
+	if len(record) != len(s.columns) {
+		row.Err = fmt.Errorf("expected %d fields, got %d", len(s.columns), len(record))
+		return row, nil
+	}
+
+	request := &PersonCreatRequest{
+		Name:    s.field(record, "name"),
+		Address: s.field(record, "address"),
+		Work:    s.field(record, "work"),
+	}
+
+	if age := s.field(record, "age"); age != "" {
+		request.Age, err = strconv.Atoi(age)
+		if err != nil {
+			row.Err = errors.New("age must be an integer")
+			return row, nil
+		}
+	}
+
+	row.Person = PersonCreatRequestToBL(request)
+
+	return row, nil
+}
+
+func (s *personCSVSource) field(record []string, column string) string {
+	return record[s.columns[column]]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"bmstu-dips-lab1/pkg/vcard"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PersonETag is the strong entity tag of the JSON representation of a
+// person in its version.
+func PersonETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// PersonFormatETag is the strong entity tag of a person in its version and
+// the negotiated format, JSON keeps the plain PersonETag.
+func PersonFormatETag(version int, format string) string {
+	if format == vcard.MIMEType {
+		return `"` + strconv.Itoa(version) + `-vcard"`
+	}
+
+	return PersonETag(version)
+}
+
+// IfMatchVersion reads the version a write is conditional on from If-Match.
+// Zero means any version: the header is "*" or absent and not required.
+// Weak and foreign tags never match, as If-Match compares strongly.
+func IfMatchVersion(c *gin.Context, required bool) (int, error) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+
+	if ifMatch == "" {
+		if required {
+			return 0, errs.ErrPreconditionRequired
+		}
+
+		return 0, nil
+	}
+
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	if strings.Contains(ifMatch, ",") {
+		return 0, errs.Invalid("If-Match must carry a single entity tag")
+	}
+
+	// any representation of the version will do
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.Trim(ifMatch, `"`), "-vcard"))
+	if err != nil || version < 1 ||
+		(ifMatch != PersonETag(version) && ifMatch != PersonFormatETag(version, vcard.MIMEType)) {
+		return 0, errs.ErrPreconditionFailed
+	}
+
+	return version, nil
+}
+
+// CollectionETag is the weak entity tag of a listing. It changes with the
+// number of matched persons and their last change, and differs between the
+// query params and formats of the same collection.
+func CollectionETag(stat *models.PersonStat, query string, format string) string {
+	sum := sha1.Sum([]byte(strings.Join([]string{
+		strconv.FormatInt(stat.Count, 10),
+		strconv.FormatInt(stat.LastModified.UnixNano(), 10),
+		query,
+		format,
+	}, "\n")))
+
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// PageETag is the weak entity tag of a page of a listing. It changes with
+// the persons on the page, their versions and the page that follows, and
+// differs between the query params and formats of the same collection.
+func PageETag(page *models.PersonPage, query string, format string) string {
+	parts := make([]string, 0, len(page.Persons)+3)
+
+	for _, p := range page.Persons {
+		parts = append(parts, strconv.Itoa(p.Id)+":"+strconv.Itoa(p.Version))
+	}
+
+	next := ""
+	if page.Next != nil {
+		next = strconv.Itoa(page.Next.Id)
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(append(parts, next, query, format), "\n")))
+
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// NotModified sets the validators of a representation and answers 304 when
+// the conditional GET of the client matches them. If-None-Match, compared
+// weakly, wins over If-Modified-Since.
+func NotModified(c *gin.Context, etag string, modified time.Time) bool {
+	c.Header("ETag", etag)
+
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if !etagListMatches(ifNoneMatch, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	c.AbortWithStatus(http.StatusNotModified)
+
+	return true
+}
+
+func etagListMatches(list string, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,20 +1,36 @@
 package http
 
 import (
+	"bmstu-dips-lab1/config"
 	"bmstu-dips-lab1/internal/person"
 	"bmstu-dips-lab1/models"
 	"bmstu-dips-lab1/pkg/errs"
+	"bmstu-dips-lab1/pkg/vcard"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+const (
+	maxBatchSize = 5000
+
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// PersonCreatRequest leaves what is required, name included, to the
+// validation rules of the config
 type PersonCreatRequest struct {
-	Name    string `json:"name" binding:"required"`
-	Address string `json:"address" binding:"required"`
-	Work    string `json:"work" binding:"required"`
-	Age     int    `json:"age" binding:"required"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Work    string `json:"work"`
+	Age     int    `json:"age"`
 }
 
 type PersonUpdRequest struct {
@@ -32,16 +48,96 @@ type PersonResponse struct {
 	Age     int    `json:"age"`
 }
 
+type PageResponse struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
 type PersonGetAllResponse struct {
 	Persons []*PersonResponse `json:"persons"`
+	Page    *PageResponse     `json:"page"`
+}
+
+// TrashedPersonResponse tells the version of a person in the trash, so that
+// its revert can be conditional on it as any other write
+type TrashedPersonResponse struct {
+	*PersonResponse
+	Version   int       `json:"version"`
+	ETag      string    `json:"etag"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type PersonTrashResponse struct {
+	Persons []*TrashedPersonResponse `json:"persons"`
+	Page    *PageResponse            `json:"page"`
+}
+
+// PersonChangeResponse is a field value before and after a revision, null
+// where the person was not there
+type PersonChangeResponse struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+type PersonRevisionResponse struct {
+	Revision  int64                            `json:"revision"`
+	Version   int                              `json:"version"`
+	Operation models.PersonOperation           `json:"operation"`
+	Actor     string                           `json:"actor"`
+	ChangedAt time.Time                        `json:"changed_at"`
+	Changes   map[string]*PersonChangeResponse `json:"changes"`
+}
+
+type PersonHistoryResponse struct {
+	Revisions []*PersonRevisionResponse `json:"revisions"`
+	Page      *PageResponse             `json:"page"`
+}
+
+type PersonHitResponse struct {
+	*PersonResponse
+	Score float32 `json:"score"`
+}
+
+type PersonSearchResponse struct {
+	Persons []*PersonHitResponse `json:"persons"`
+	Page    *PageResponse        `json:"page"`
+}
+
+type PersonBatchItemResponse struct {
+	Index    int               `json:"index"`
+	Status   int               `json:"status"`
+	Id       int               `json:"id,omitempty"`
+	Location string            `json:"location,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+type PersonBatchResponse struct {
+	Mode    models.BatchMode           `json:"mode"`
+	Results []*PersonBatchItemResponse `json:"results"`
+}
+
+type ImportRowErrorResponse struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type ImportReportResponse struct {
+	Imported      int64                     `json:"imported"`
+	Rejected      []*ImportRowErrorResponse `json:"rejected"`
+	DurationMs    int64                     `json:"duration_ms"`
+	RowsPerSecond float64                   `json:"rows_per_second"`
 }
 
 type PersonHandlers struct {
+	cfg      *config.Config
 	personUC person.UseCase
 }
 
-func NewPersonHandlers(personUC person.UseCase) person.Handlers {
+func NewPersonHandlers(cfg *config.Config, personUC person.UseCase) person.Handlers {
 	return &PersonHandlers{
+		cfg:      cfg,
 		personUC: personUC,
 	}
 }
@@ -52,85 +148,300 @@ func (p *PersonHandlers) Create() gin.HandlerFunc {
 
 		err := c.ShouldBindJSON(request)
 		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+			errs.Abort(c, errs.FromBinding(err))
 			return
 		}
 
+		key, err := IdempotencyKey(c)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		var idempotent *models.IdempotencyKey
+
+		if key != "" {
+			hash, err := IdempotencyRequestHash(request)
+			if err != nil {
+				errs.Abort(c, err)
+				return
+			}
+
+			stored, reserved, err := p.personUC.ReserveIdempotencyKey(c, &models.IdempotencyKey{Key: key, RequestHash: hash})
+			if err != nil {
+				errs.Abort(c, err)
+				return
+			}
+
+			if !reserved {
+				ReplayIdempotent(c, stored)
+				return
+			}
+
+			idempotent = stored
+		}
+
 		modelBL := PersonCreatRequestToBL(request)
 
 		createdperson, err := p.personUC.Create(c, modelBL)
 		if err != nil {
-			c.AbortWithStatus(errs.MatchHttpErr(err))
+			if idempotent != nil {
+				if releaseErr := p.personUC.ReleaseIdempotencyKey(c, idempotent); releaseErr != nil {
+					c.Error(releaseErr)
+				}
+			}
+
+			errs.Abort(c, err)
 			return
 		}
 
-		c.Header("Location", "/api/v1/persons/"+strconv.Itoa(createdperson.Id))
+		location := PersonLocation(createdperson.Id)
+		etag := PersonETag(createdperson.Version)
+
+		// the person is there already, a key left in flight only makes
+		// retries get 409 until it expires
+		if idempotent != nil {
+			idempotent.Status, idempotent.Location, idempotent.ETag = http.StatusCreated, location, etag
+
+			if err = p.personUC.CompleteIdempotencyKey(c, idempotent); err != nil {
+				c.Error(err)
+			}
+		}
+
+		c.Header("Location", location)
+		c.Header("ETag", etag)
 
 		c.Status(http.StatusCreated)
 	}
 }
 
-func (p *PersonHandlers) Update() gin.HandlerFunc {
+// CreateMany creates persons from a JSON array. Every item is validated
+// first: in the atomic mode a single invalid item fails the whole batch,
+// in the best effort mode it is reported and skipped.
+func (p *PersonHandlers) CreateMany() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		request := new(PersonUpdRequest)
+		mode := models.BatchMode(c.DefaultQuery("mode", string(models.BatchModeAtomic)))
+		if mode != models.BatchModeAtomic && mode != models.BatchModeBestEffort {
+			errs.Abort(c, errs.Invalid("mode must be %q or %q", models.BatchModeAtomic, models.BatchModeBestEffort))
+			return
+		}
 
-		err := c.ShouldBindJSON(request)
+		requests := make([]*PersonCreatRequest, 0)
+
+		// items are validated one by one by the usecase, so the body is not
+		// bound as a whole
+		err := json.NewDecoder(c.Request.Body).Decode(&requests)
 		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+			errs.Abort(c, errs.Invalid("body must be an array of persons"))
 			return
 		}
 
-		intid, err := strconv.Atoi(c.Param("personid"))
+		if len(requests) == 0 || len(requests) > maxBatchSize {
+			errs.Abort(c, errs.Invalid("batch must contain from 1 to %d persons", maxBatchSize))
+			return
+		}
+
+		persons := make([]*models.Person, len(requests))
+
+		for i, request := range requests {
+			if request == nil {
+				errs.Abort(c, errs.Invalid("body must be an array of persons, item %d is null", i))
+				return
+			}
+
+			persons[i] = PersonCreatRequestToBL(request)
+		}
+
+		created, err := p.personUC.CreateMany(c, persons, mode)
 		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+			errs.Abort(c, err)
+			return
+		}
+
+		res := &PersonBatchResponse{
+			Mode:    mode,
+			Results: make([]*PersonBatchItemResponse, len(created)),
 		}
 
-		const updStr string = "y"
-		const updInt int = 1
+		status := http.StatusCreated
 
-		modelBL := &models.Person{Id: intid}
-		toUpdate := &models.Person{}
+		for i, result := range created {
+			if result.Err != nil {
+				res.Results[i] = BatchItemErrorResponse(i, result.Err)
+				status = http.StatusMultiStatus
+				continue
+			}
 
-		if request.Name != nil {
-			modelBL.Name = *request.Name
-			toUpdate.Name = updStr
+			res.Results[i] = &PersonBatchItemResponse{
+				Index:    i,
+				Status:   http.StatusCreated,
+				Id:       result.Person.Id,
+				Location: PersonLocation(result.Person.Id),
+			}
 		}
 
-		if request.Address != nil {
-			modelBL.Address = *request.Address
-			toUpdate.Address = updStr
+		// in the atomic mode only invalid persons stop the batch short of the repo
+		if status != http.StatusCreated && mode == models.BatchModeAtomic {
+			status = http.StatusBadRequest
+		}
+
+		c.JSON(status, res)
+	}
+}
+
+// Update is PATCH. A merge patch or a json patch is applied by its RFC, a
+// plain json body sets its fields that are not null and keeps the rest.
+func (p *PersonHandlers) Update() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		intid, err := strconv.Atoi(c.Param("personid"))
+		if err != nil {
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
 		}
 
-		if request.Work != nil {
-			modelBL.Work = *request.Work
-			toUpdate.Work = updStr
+		version, err := IfMatchVersion(c, p.cfg.Persons.RequireIfMatch)
+		if err != nil {
+			errs.Abort(c, err)
+			return
 		}
 
-		if request.Age != nil {
-			modelBL.Age = *request.Age
-			toUpdate.Age = updInt
+		var modelBL *models.Person
+		var mask models.PersonMask
+
+		switch c.ContentType() {
+		case MIMEMergePatch:
+			modelBL, mask, err = ParseMergePatch(c.Request.Body, intid)
+		case MIMEJSONPatch:
+			var current *models.Person
+
+			current, err = p.personUC.GetById(c, intid)
+			if err == nil {
+				modelBL, mask, err = ApplyJSONPatch(current, c.Request.Body)
+			}
+		default:
+			request := new(PersonUpdRequest)
+
+			err = c.ShouldBindJSON(request)
+			if err != nil {
+				err = errs.FromBinding(err)
+				break
+			}
+
+			modelBL, mask = PersonUpdRequestToBL(request, intid)
 		}
 
-		updatedperson, err := p.personUC.Update(c, modelBL, toUpdate)
 		if err != nil {
-			c.AbortWithStatus(errs.MatchHttpErr(err))
+			errs.Abort(c, err)
 			return
 		}
 
+		// a json patch is already bound to the version it was applied to
+		if version != 0 {
+			modelBL.Version = version
+		}
+
+		updatedperson, err := p.personUC.Update(c, modelBL, mask)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Header("ETag", PersonETag(updatedperson.Version))
 		c.JSON(http.StatusOK, PersonBLToResponse(updatedperson))
 	}
 }
 
+// Replace is PUT: the body is the whole new person, omitted fields are not
+// kept but cleared. If-Match is required as on PATCH, except for a PUT that
+// may create the person and says so with "If-None-Match: *", which then
+// fails on a person that exists.
+func (p *PersonHandlers) Replace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := new(PersonCreatRequest)
+
+		err := c.ShouldBindJSON(request)
+		if err != nil {
+			errs.Abort(c, errs.FromBinding(err))
+			return
+		}
+
+		// a PUT may create the person, so the id has to be a valid one
+		intid, err := strconv.Atoi(c.Param("personid"))
+		if err != nil || intid < 1 {
+			errs.Abort(c, errs.Invalid("personid must be a positive integer"))
+			return
+		}
+
+		createOnly := p.cfg.Persons.UpsertOnPut && strings.TrimSpace(c.GetHeader("If-None-Match")) == "*"
+
+		if createOnly && c.GetHeader("If-Match") != "" {
+			errs.Abort(c, errs.Invalid("If-Match and If-None-Match: * exclude each other"))
+			return
+		}
+
+		version, err := IfMatchVersion(c, p.cfg.Persons.RequireIfMatch && !createOnly)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		modelBL := PersonCreatRequestToBL(request)
+		modelBL.Id = intid
+		modelBL.Version = version
+
+		replacedperson, created, err := p.personUC.Replace(c, modelBL, createOnly)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Header("ETag", PersonETag(replacedperson.Version))
+
+		if created {
+			c.Header("Location", PersonLocation(replacedperson.Id))
+			c.JSON(http.StatusCreated, PersonBLToResponse(replacedperson))
+			return
+		}
+
+		c.JSON(http.StatusOK, PersonBLToResponse(replacedperson))
+	}
+}
+
 func (p *PersonHandlers) GetById() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		intid, err := strconv.Atoi(c.Param("personid"))
 		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
 		}
 
-		foundperson, err := p.personUC.GetById(c, intid)
+		asOf, err := ParseAsOf(c)
 		if err != nil {
-			c.AbortWithStatus(errs.MatchHttpErr(err))
+			errs.Abort(c, err)
+			return
+		}
+
+		var foundperson *models.Person
+
+		if asOf != nil {
+			foundperson, err = p.personUC.GetAsOf(c, intid, *asOf)
+		} else {
+			foundperson, err = p.personUC.GetById(c, intid)
+		}
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		format := c.NegotiateFormat(binding.MIMEJSON, vcard.MIMEType)
+
+		c.Header("Vary", "Accept")
+		if NotModified(c, PersonFormatETag(foundperson.Version, format), foundperson.UpdatedAt) {
+			return
+		}
+
+		if format == vcard.MIMEType {
+			WritePersons(c, vcard.MIMEType, []*models.Person{foundperson})
 			return
 		}
 
@@ -142,12 +453,19 @@ func (p *PersonHandlers) Delete() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		intid, err := strconv.Atoi(c.Param("personid"))
 		if err != nil {
-			c.AbortWithStatus(http.StatusBadRequest)
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
+		}
+
+		version, err := IfMatchVersion(c, p.cfg.Persons.RequireIfMatch)
+		if err != nil {
+			errs.Abort(c, err)
+			return
 		}
 
-		err = p.personUC.Delete(c, intid)
+		err = p.personUC.Delete(c, intid, version)
 		if err != nil {
-			c.AbortWithStatus(errs.MatchHttpErr(err))
+			errs.Abort(c, err)
 			return
 		}
 
@@ -155,18 +473,426 @@ func (p *PersonHandlers) Delete() gin.HandlerFunc {
 	}
 }
 
+// Trash lists the deleted persons, it is always paginated.
+func (p *PersonHandlers) Trash() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := CheckQueryParams(c, trashQueryParams)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		page, paginated, err := ParsePage(c)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		if !paginated {
+			page.Limit = defaultPageLimit
+		}
+
+		trashed, err := p.personUC.Trash(c, page)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, PersonTrashBLToResponse(trashed, page))
+	}
+}
+
+func (p *PersonHandlers) Restore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		intid, err := strconv.Atoi(c.Param("personid"))
+		if err != nil {
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
+		}
+
+		restoredperson, err := p.personUC.Restore(c, intid)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Header("ETag", PersonETag(restoredperson.Version))
+
+		c.JSON(http.StatusOK, PersonBLToResponse(restoredperson))
+	}
+}
+
+// Purge removes a person from the trash for good, a person has to be
+// deleted first.
+func (p *PersonHandlers) Purge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		intid, err := strconv.Atoi(c.Param("personid"))
+		if err != nil {
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
+		}
+
+		err = p.personUC.Purge(c, intid)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// History lists the revisions of a person newest first, the person may be
+// deleted or purged already.
+func (p *PersonHandlers) History() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		intid, err := strconv.Atoi(c.Param("personid"))
+		if err != nil {
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
+		}
+
+		err = CheckQueryParams(c, historyQueryParams)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		page, err := ParseHistoryPage(c)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		history, err := p.personUC.History(c, intid, page)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		res, err := PersonHistoryBLToResponse(history, page)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// Revert brings a person back to a revision of its history, If-Match
+// guards it like any other write.
+func (p *PersonHandlers) Revert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		intid, err := strconv.Atoi(c.Param("personid"))
+		if err != nil {
+			errs.Abort(c, errs.Invalid("personid must be an integer"))
+			return
+		}
+
+		rev, err := strconv.ParseInt(c.Param("rev"), 10, 64)
+		if err != nil || rev < 1 {
+			errs.Abort(c, errs.Invalid("rev must be a positive integer"))
+			return
+		}
+
+		version, err := IfMatchVersion(c, p.cfg.Persons.RequireIfMatch)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		revertedperson, err := p.personUC.Revert(c, intid, rev, version)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Header("ETag", PersonETag(revertedperson.Version))
+
+		c.JSON(http.StatusOK, PersonBLToResponse(revertedperson))
+	}
+}
+
 func (p *PersonHandlers) GetAll() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		foundpersons, err := p.personUC.GetAll(c)
+		err := CheckQueryParams(c, listQueryParams)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		filter, err := ParsePersonFilter(c)
 		if err != nil {
-			c.AbortWithStatus(errs.MatchHttpErr(err))
+			errs.Abort(c, err)
+			return
+		}
+
+		page, paginated, err := ParsePage(c)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		format := c.NegotiateFormat(binding.MIMEJSON, MIMENDJSON, vcard.MIMEType)
+		streamed := format == MIMENDJSON || format == vcard.MIMEType
+
+		c.Header("Vary", "Accept")
+
+		// a stream is sent as it is read, its validators are known ahead
+		// only from the stat of the whole collection. A listing has no
+		// Last-Modified, a person deleted from it leaves its last change
+		// where it was, so only the ETag tells the client it has changed
+		if streamed && !paginated {
+			stat, err := p.personUC.Stat(c, filter)
+			if err != nil {
+				errs.Abort(c, err)
+				return
+			}
+
+			if NotModified(c, CollectionETag(stat, c.Request.URL.RawQuery, format), time.Time{}) {
+				return
+			}
+
+			StreamPersons(c, format, func(fn func(*models.Person) error) error {
+				return p.personUC.ForEach(c, filter, page.Sort, fn)
+			})
+			return
+		}
+
+		foundpersons, err := p.personUC.GetAll(c, filter, page)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		if NotModified(c, PageETag(foundpersons, c.Request.URL.RawQuery, format), time.Time{}) {
+			return
+		}
+
+		if streamed || !paginated {
+			if foundpersons.Next != nil {
+				query := c.Request.URL.Query()
+				query.Set("cursor", EncodeCursor(foundpersons.Next))
+				query.Set("limit", strconv.Itoa(page.Limit))
+				c.Header("Link", "<"+c.Request.URL.Path+"?"+query.Encode()+`>; rel="next"`)
+			}
+		}
+
+		if streamed {
+			WritePersons(c, format, foundpersons.Persons)
+			return
+		}
+
+		// without limit and cursor the plain array from the lab contract is
+		// kept, what does not fit in it is behind the Link header
+		if !paginated {
+			c.JSON(http.StatusOK, PersonsBLToResponse(foundpersons.Persons))
 			return
 		}
 
-		c.JSON(http.StatusOK, PersonsBLToResponse(foundpersons))
+		c.JSON(http.StatusOK, PersonPageBLToResponse(foundpersons, page))
 	}
 }
 
+func (p *PersonHandlers) Search() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := CheckQueryParams(c, searchQueryParams)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		search, err := ParsePersonSearch(c, p.cfg.Search.FuzzyThreshold)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		page, err := ParseSearchPage(c)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		foundhits, err := p.personUC.Search(c, search, page)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, PersonHitPageBLToResponse(foundhits, page))
+	}
+}
+
+// ExportCSV streams all persons as CSV right from the repo cursor.
+func (p *PersonHandlers) ExportCSV() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="persons.csv"`)
+		c.Status(http.StatusOK)
+
+		w, err := NewPersonCSVWriter(c.Writer, c.Writer)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		err = p.personUC.ForEach(c, &models.PersonFilter{}, nil, w.Write)
+		if err != nil {
+			AbortStream(c, err)
+			return
+		}
+
+		err = w.Flush()
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// Import imports persons from a CSV or vCard file sent either as the "file"
+// field of a multipart form or as a text/csv or text/vcard body. Invalid
+// records are reported by line and do not prevent the valid ones from being
+// imported.
+func (p *PersonHandlers) Import() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := io.Reader(c.Request.Body)
+		isVCard := c.ContentType() == vcard.MIMEType || c.ContentType() == "text/x-vcard"
+
+		if c.ContentType() == binding.MIMEMultipartPOSTForm {
+			fileHeader, err := c.FormFile("file")
+			if err != nil {
+				errs.Abort(c, errs.Invalid("file field is missing"))
+				return
+			}
+
+			file, err := fileHeader.Open()
+			if err != nil {
+				errs.Abort(c, err)
+				return
+			}
+			defer file.Close()
+
+			body = file
+			isVCard = strings.HasSuffix(strings.ToLower(fileHeader.Filename), ".vcf") ||
+				strings.HasPrefix(fileHeader.Header.Get("Content-Type"), vcard.MIMEType)
+		}
+
+		var src models.PersonSource
+
+		if isVCard {
+			src = NewPersonVCardSource(body)
+		} else {
+			var err error
+
+			src, err = NewPersonCSVSource(body)
+			if err != nil {
+				errs.Abort(c, err)
+				return
+			}
+		}
+
+		report, err := p.personUC.Import(c, src)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, ImportReportBLToResponse(report))
+	}
+}
+
+func WritePersons(c *gin.Context, format string, persons []*models.Person) {
+	StreamPersons(c, format, func(fn func(*models.Person) error) error {
+		for _, person := range persons {
+			err := fn(person)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// StreamPersons writes every person passed to the callback of forEach as
+// a vCard or as a line of NDJSON, flushing them to the client as it goes.
+func StreamPersons(c *gin.Context, format string, forEach func(fn func(*models.Person) error) error) {
+	c.Header("Content-Type", format+"; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	var encode func(*models.Person) error
+
+	switch format {
+	case vcard.MIMEType:
+		enc := vcard.NewEncoder(c.Writer)
+		encode = func(person *models.Person) error {
+			return enc.Encode(PersonToCard(person))
+		}
+	default:
+		enc := json.NewEncoder(c.Writer)
+		encode = func(person *models.Person) error {
+			return enc.Encode(PersonBLToResponse(person))
+		}
+	}
+
+	written := 0
+
+	err := forEach(func(person *models.Person) error {
+		err := encode(person)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%streamFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		AbortStream(c, err)
+	}
+}
+
+// AbortStream reports an error of a streamed response. Once the body has
+// started, the status is already sent and a truncated body is all we can do.
+func AbortStream(c *gin.Context, err error) {
+	if !c.Writer.Written() {
+		errs.Abort(c, err)
+		return
+	}
+
+	c.Error(err)
+}
+
+func PersonLocation(id int) string {
+	return "/api/v1/persons/" + strconv.Itoa(id)
+}
+
+// BatchItemErrorResponse reports a failed batch item the way errs.Abort
+// would report the same error of a single request.
+func BatchItemErrorResponse(index int, err error) *PersonBatchItemResponse {
+	status := errs.MatchHttpErr(err)
+	res := &PersonBatchItemResponse{Index: index, Status: status}
+
+	var invalid *errs.InvalidError
+
+	switch {
+	case status == http.StatusInternalServerError:
+		res.Error = http.StatusText(status)
+	case errors.As(err, &invalid):
+		res.Error = invalid.Message
+		res.Errors = invalid.Fields
+	default:
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
 func PersonCreatRequestToBL(dto *PersonCreatRequest) *models.Person {
 	return &models.Person{
 		Name:    dto.Name,
@@ -176,6 +902,33 @@ func PersonCreatRequestToBL(dto *PersonCreatRequest) *models.Person {
 	}
 }
 
+func PersonUpdRequestToBL(dto *PersonUpdRequest, id int) (*models.Person, models.PersonMask) {
+	modelBL := &models.Person{Id: id}
+	mask := models.NewPersonMask()
+
+	if dto.Name != nil {
+		modelBL.Name = *dto.Name
+		mask[models.PersonFieldName] = true
+	}
+
+	if dto.Address != nil {
+		modelBL.Address = *dto.Address
+		mask[models.PersonFieldAddress] = true
+	}
+
+	if dto.Work != nil {
+		modelBL.Work = *dto.Work
+		mask[models.PersonFieldWork] = true
+	}
+
+	if dto.Age != nil {
+		modelBL.Age = *dto.Age
+		mask[models.PersonFieldAge] = true
+	}
+
+	return modelBL, mask
+}
+
 func PersonBLToResponse(modelBL *models.Person) *PersonResponse {
 	return &PersonResponse{
 		Id:      modelBL.Id,
@@ -199,3 +952,142 @@ func PersonsBLToResponse(persons []*models.Person) []*PersonResponse {
 
 	return res
 }
+
+func PersonPageBLToResponse(personPage *models.PersonPage, page *models.Page) *PersonGetAllResponse {
+	return &PersonGetAllResponse{
+		Persons: PersonsBLToResponse(personPage.Persons),
+		Page: &PageResponse{
+			Limit:      page.Limit,
+			HasMore:    personPage.Next != nil,
+			NextCursor: EncodeCursor(personPage.Next),
+		},
+	}
+}
+
+func PersonTrashBLToResponse(personPage *models.PersonPage, page *models.Page) *PersonTrashResponse {
+	res := &PersonTrashResponse{
+		Persons: make([]*TrashedPersonResponse, len(personPage.Persons)),
+		Page: &PageResponse{
+			Limit:      page.Limit,
+			HasMore:    personPage.Next != nil,
+			NextCursor: EncodeCursor(personPage.Next),
+		},
+	}
+
+	for i, p := range personPage.Persons {
+		res.Persons[i] = &TrashedPersonResponse{
+			PersonResponse: PersonBLToResponse(p),
+			Version:        p.Version,
+			ETag:           PersonETag(p.Version),
+			DeletedAt:      p.DeletedAt,
+		}
+	}
+
+	return res
+}
+
+func PersonHistoryBLToResponse(history *models.PersonRevisionPage, page *models.HistoryPage) (*PersonHistoryResponse, error) {
+	res := &PersonHistoryResponse{
+		Revisions: make([]*PersonRevisionResponse, len(history.Revisions)),
+		Page: &PageResponse{
+			Limit:      page.Limit,
+			HasMore:    history.Next != nil,
+			NextCursor: EncodeRevisionCursor(history.Next),
+		},
+	}
+
+	for i, rev := range history.Revisions {
+		changes, err := PersonChanges(rev.Before, rev.After)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Revisions[i] = &PersonRevisionResponse{
+			Revision:  rev.Revision,
+			Version:   rev.Version,
+			Operation: rev.Operation,
+			Actor:     rev.Actor,
+			ChangedAt: rev.ChangedAt,
+			Changes:   changes,
+		}
+	}
+
+	return res, nil
+}
+
+// PersonChanges diffs the writable fields of two states of a person, a nil
+// state has every field null.
+func PersonChanges(before, after *models.Person) (map[string]*PersonChangeResponse, error) {
+	from, to := map[models.PersonField]json.RawMessage{}, map[models.PersonField]json.RawMessage{}
+	var err error
+
+	if before != nil {
+		from, err = personDocument(before)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if after != nil {
+		to, err = personDocument(after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make(map[string]*PersonChangeResponse)
+
+	for _, field := range models.PersonFields {
+		change := &PersonChangeResponse{From: from[field], To: to[field]}
+
+		if change.From == nil && change.To == nil {
+			continue
+		}
+
+		if change.From != nil && change.To != nil && jsonEqual(change.From, change.To) {
+			continue
+		}
+
+		changes[string(field)] = change
+	}
+
+	return changes, nil
+}
+
+func PersonHitPageBLToResponse(hitPage *models.PersonHitPage, page *models.SearchPage) *PersonSearchResponse {
+	res := &PersonSearchResponse{
+		Persons: make([]*PersonHitResponse, len(hitPage.Hits)),
+		Page: &PageResponse{
+			Limit:      page.Limit,
+			HasMore:    hitPage.Next != nil,
+			NextCursor: EncodeHitCursor(hitPage.Next),
+		},
+	}
+
+	for i, hit := range hitPage.Hits {
+		res.Persons[i] = &PersonHitResponse{
+			PersonResponse: PersonBLToResponse(hit.Person),
+			Score:          hit.Rank,
+		}
+	}
+
+	return res
+}
+
+func ImportReportBLToResponse(report *models.ImportReport) *ImportReportResponse {
+	res := &ImportReportResponse{
+		Imported:      report.Imported,
+		Rejected:      make([]*ImportRowErrorResponse, len(report.Rejected)),
+		DurationMs:    report.Duration.Milliseconds(),
+		RowsPerSecond: report.RowsPerSecond(),
+	}
+
+	for i, row := range report.Rejected {
+		res.Rejected[i] = &ImportRowErrorResponse{
+			Line:  row.Line,
+			Error: row.Err.Error(),
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+)
+
+// IdempotencyKey reads the Idempotency-Key header, an empty key means the
+// request is not idempotent.
+func IdempotencyKey(c *gin.Context) (string, error) {
+	key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+
+	if len(key) > maxIdempotencyKeyLen {
+		return "", errs.Invalid("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLen)
+	}
+
+	return key, nil
+}
+
+// IdempotencyRequestHash hashes the bound request rather than the raw body,
+// so that a retry differing in whitespace or key order is the same request.
+func IdempotencyRequestHash(request interface{}) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ReplayIdempotent answers with the response stored for key. The ETag is
+// the one of the create, the person may have changed since.
+func ReplayIdempotent(c *gin.Context, key *models.IdempotencyKey) {
+	if key.Location != "" {
+		c.Header("Location", key.Location)
+	}
+	if key.ETag != "" {
+		c.Header("ETag", key.ETag)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+
+	c.Status(key.Status)
+}
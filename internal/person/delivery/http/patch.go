@@ -0,0 +1,268 @@
+package http
+
+import (
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+var jsonNull = json.RawMessage("null")
+
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ParseMergePatch reads an RFC 7396 merge patch of the person id: a member
+// sets its field, null clears it and an absent member keeps it.
+func ParseMergePatch(r io.Reader, id int) (*models.Person, models.PersonMask, error) {
+	patch := make(map[string]json.RawMessage)
+
+	err := json.NewDecoder(r).Decode(&patch)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, nil, errs.Invalid("merge patch must be an object")
+		}
+
+		return nil, nil, errs.FromBinding(err)
+	}
+
+	modelBL := &models.Person{Id: id}
+	mask := models.NewPersonMask()
+	fields := make(map[string]string)
+
+	for name, value := range patch {
+		field := models.PersonField(name)
+
+		msg := checkPatchField(field)
+		if msg == "" {
+			msg = SetPersonField(modelBL, field, value)
+		}
+
+		if msg != "" {
+			fields[name] = msg
+			continue
+		}
+
+		mask[field] = true
+	}
+
+	if len(fields) != 0 {
+		return nil, nil, errs.InvalidFields(fields)
+	}
+
+	return modelBL, mask, nil
+}
+
+// ApplyJSONPatch applies an RFC 6902 patch to current. Paths point at the
+// members of a person like "/name", remove clears the field. A failed test
+// operation fails the whole patch with errs.ErrConflict.
+func ApplyJSONPatch(current *models.Person, r io.Reader) (*models.Person, models.PersonMask, error) {
+	ops := make([]*jsonPatchOp, 0)
+
+	err := json.NewDecoder(r).Decode(&ops)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, nil, errs.Invalid("json patch must be an array of operations")
+		}
+
+		return nil, nil, errs.FromBinding(err)
+	}
+
+	doc, err := personDocument(current)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mask := models.NewPersonMask()
+
+	for i, op := range ops {
+		if op == nil {
+			return nil, nil, errs.Invalid("operation %d must be an object", i)
+		}
+
+		path, err := patchPath(op.Path)
+		if err != nil {
+			return nil, nil, errs.Invalid("operation %d: %s", i, err)
+		}
+
+		if op.Op != "test" {
+			if msg := checkPatchField(path); msg != "" {
+				return nil, nil, errs.Invalid("operation %d: %s %s", i, path, msg)
+			}
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return nil, nil, errs.Invalid("operation %d: value is missing", i)
+			}
+
+			doc[path] = op.Value
+			mask[path] = true
+		case "remove":
+			doc[path] = jsonNull
+			mask[path] = true
+		case "copy", "move":
+			from, err := patchPath(op.From)
+			if err != nil {
+				return nil, nil, errs.Invalid("operation %d: %s", i, err)
+			}
+
+			doc[path] = doc[from]
+			mask[path] = true
+
+			if op.Op == "move" {
+				if msg := checkPatchField(from); msg != "" {
+					return nil, nil, errs.Invalid("operation %d: %s %s", i, from, msg)
+				}
+
+				if from != path {
+					doc[from] = jsonNull
+					mask[from] = true
+				}
+			}
+		case "test":
+			if op.Value == nil {
+				return nil, nil, errs.Invalid("operation %d: value is missing", i)
+			}
+
+			if !jsonEqual(doc[path], op.Value) {
+				return nil, nil, fmt.Errorf("operation %d: test of %s failed: %w", i, path, errs.ErrConflict)
+			}
+		default:
+			return nil, nil, errs.Invalid("operation %d: op %q is not supported", i, op.Op)
+		}
+	}
+
+	modelBL := *current
+	fields := make(map[string]string)
+
+	for field := range mask {
+		if msg := SetPersonField(&modelBL, field, doc[field]); msg != "" {
+			fields[string(field)] = msg
+		}
+	}
+
+	if len(fields) != 0 {
+		return nil, nil, errs.InvalidFields(fields)
+	}
+
+	return &modelBL, mask, nil
+}
+
+// SetPersonField sets the field of modelBL to a JSON value, null sets the
+// zero value. It returns what is wrong with the value, if anything.
+func SetPersonField(modelBL *models.Person, field models.PersonField, value json.RawMessage) string {
+	isNull := bytes.Equal(bytes.TrimSpace(value), jsonNull)
+
+	if field == models.PersonFieldAge {
+		age := 0
+		if !isNull && json.Unmarshal(value, &age) != nil {
+			return "must be an integer"
+		}
+
+		modelBL.Age = age
+		return ""
+	}
+
+	var target *string
+
+	switch field {
+	case models.PersonFieldName:
+		target = &modelBL.Name
+	case models.PersonFieldAddress:
+		target = &modelBL.Address
+	case models.PersonFieldWork:
+		target = &modelBL.Work
+	default:
+		return "is unknown"
+	}
+
+	str := ""
+	if !isNull && json.Unmarshal(value, &str) != nil {
+		return "must be a string"
+	}
+
+	*target = str
+
+	return ""
+}
+
+func checkPatchField(field models.PersonField) string {
+	if field == models.PersonFieldId {
+		return "cannot be changed"
+	}
+
+	if !isPersonField(field) {
+		return "is unknown"
+	}
+
+	return ""
+}
+
+func isPersonField(field models.PersonField) bool {
+	for _, f := range models.PersonFields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// patchPath takes the field out of a JSON pointer, persons have no nested
+// members.
+func patchPath(pointer string) (models.PersonField, error) {
+	if !strings.HasPrefix(pointer, "/") || strings.Count(pointer, "/") != 1 {
+		return "", fmt.Errorf("path %q must point at a person field", pointer)
+	}
+
+	field := models.PersonField(strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:]))
+	if field != models.PersonFieldId && !isPersonField(field) {
+		return "", fmt.Errorf("path %q must point at a person field", pointer)
+	}
+
+	return field, nil
+}
+
+func personDocument(modelBL *models.Person) (map[models.PersonField]json.RawMessage, error) {
+	raw, err := json.Marshal(PersonBLToResponse(modelBL))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[models.PersonField]json.RawMessage)
+
+	err = json.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
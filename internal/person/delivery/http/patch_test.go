@@ -0,0 +1,239 @@
+package http_test
+
+import (
+	h "bmstu-dips-lab1/internal/person/delivery/http"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _current = models.Person{
+	Id:      345,
+	Name:    "Ivan",
+	Address: "Moscow",
+	Work:    "work1",
+	Age:     30,
+	Version: 3,
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	withPerson := func(change func(p *models.Person)) models.Person {
+		p := _current
+		change(&p)
+		return p
+	}
+
+	testTable := []struct {
+		nameTest       string
+		patch          string
+		expectedPerson models.Person
+		expectedMask   models.PersonMask
+		expectedErr    error
+	}{
+		{
+			nameTest:       "replace",
+			patch:          `[{"op":"replace","path":"/name","value":"Petr"}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Name = "Petr" }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldName),
+		},
+		{
+			nameTest:       "add",
+			patch:          `[{"op":"add","path":"/age","value":31}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Age = 31 }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldAge),
+		},
+		{
+			nameTest:       "remove",
+			patch:          `[{"op":"remove","path":"/work"}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Work = "" }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldWork),
+		},
+		{
+			nameTest:       "copy",
+			patch:          `[{"op":"copy","from":"/address","path":"/work"}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Work = "Moscow" }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldWork),
+		},
+		{
+			nameTest:       "move",
+			patch:          `[{"op":"move","from":"/address","path":"/work"}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Work, p.Address = "Moscow", "" }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldWork, models.PersonFieldAddress),
+		},
+		{
+			nameTest:       "move_to_itself",
+			patch:          `[{"op":"move","from":"/work","path":"/work"}]`,
+			expectedPerson: _current,
+			expectedMask:   models.NewPersonMask(models.PersonFieldWork),
+		},
+		{
+			nameTest: "test_then_replace",
+			patch: `[{"op":"test","path":"/id","value":345},{"op":"test","path":"/age","value":30.0},` +
+				`{"op":"replace","path":"/age","value":40}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Age = 40 }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldAge),
+		},
+		{
+			nameTest:       "operations_in_order",
+			patch:          `[{"op":"replace","path":"/name","value":"Petr"},{"op":"copy","from":"/name","path":"/work"},{"op":"remove","path":"/name"}]`,
+			expectedPerson: withPerson(func(p *models.Person) { p.Name, p.Work = "", "Petr" }),
+			expectedMask:   models.NewPersonMask(models.PersonFieldName, models.PersonFieldWork),
+		},
+		{
+			nameTest:    "test_failed",
+			patch:       `[{"op":"test","path":"/name","value":"Petr"},{"op":"replace","path":"/name","value":"Oleg"}]`,
+			expectedErr: errs.ErrConflict,
+		},
+		{
+			nameTest:    "id_replaced",
+			patch:       `[{"op":"replace","path":"/id","value":1}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "id_moved",
+			patch:       `[{"op":"move","from":"/id","path":"/work"}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "unknown_path",
+			patch:       `[{"op":"add","path":"/email","value":"a@b.c"}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "nested_path",
+			patch:       `[{"op":"add","path":"/name/first","value":"Ivan"}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "escaped_path",
+			patch:       `[{"op":"remove","path":"/~1name"}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "unsupported_op",
+			patch:       `[{"op":"increment","path":"/age","value":1}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "value_missing",
+			patch:       `[{"op":"replace","path":"/name"}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "wrong_type",
+			patch:       `[{"op":"replace","path":"/age","value":"old"}]`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+		{
+			nameTest:    "not_an_array",
+			patch:       `{"op":"replace","path":"/name","value":"Petr"}`,
+			expectedErr: errs.ErrInvalidContent,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			current := _current
+
+			got, mask, err := h.ApplyJSONPatch(&current, strings.NewReader(testCase.patch))
+
+			if testCase.expectedErr != nil {
+				assert.True(t, errors.Is(err, testCase.expectedErr), "%v", err)
+				return
+			}
+
+			assert.Equal(t, nil, err)
+			assert.Equal(t, testCase.expectedPerson, *got)
+			assert.Equal(t, testCase.expectedMask, mask)
+			assert.Equal(t, _current, current)
+		})
+	}
+}
+
+func TestApplyJSONPatch_Fields(t *testing.T) {
+	t.Parallel()
+
+	current := _current
+
+	_, _, err := h.ApplyJSONPatch(&current, strings.NewReader(`[{"op":"replace","path":"/age","value":"old"},{"op":"replace","path":"/name","value":1}]`))
+
+	var invalid *errs.InvalidError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, map[string]string{"age": "must be an integer", "name": "must be a string"}, invalid.Fields)
+}
+
+func TestParseMergePatch(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest       string
+		patch          string
+		expectedPerson models.Person
+		expectedMask   models.PersonMask
+		expectedFields map[string]string
+	}{
+		{
+			nameTest:       "set",
+			patch:          `{"name":"Petr","age":31}`,
+			expectedPerson: models.Person{Id: 345, Name: "Petr", Age: 31},
+			expectedMask:   models.NewPersonMask(models.PersonFieldName, models.PersonFieldAge),
+		},
+		{
+			nameTest:       "null_clears",
+			patch:          `{"work":null,"age":null}`,
+			expectedPerson: models.Person{Id: 345},
+			expectedMask:   models.NewPersonMask(models.PersonFieldWork, models.PersonFieldAge),
+		},
+		{
+			nameTest:       "empty",
+			patch:          `{}`,
+			expectedPerson: models.Person{Id: 345},
+			expectedMask:   models.NewPersonMask(),
+		},
+		{
+			nameTest:       "id",
+			patch:          `{"id":1}`,
+			expectedFields: map[string]string{"id": "cannot be changed"},
+		},
+		{
+			nameTest:       "unknown_and_wrong_type",
+			patch:          `{"email":"a@b.c","age":"old","name":"Petr"}`,
+			expectedFields: map[string]string{"email": "is unknown", "age": "must be an integer"},
+		},
+		{
+			nameTest: "not_an_object",
+			patch:    `["name"]`,
+		},
+		{
+			nameTest: "malformed",
+			patch:    `{"name":`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			got, mask, err := h.ParseMergePatch(strings.NewReader(testCase.patch), 345)
+
+			switch testCase.nameTest {
+			case "set", "null_clears", "empty":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPerson, *got)
+				assert.Equal(t, testCase.expectedMask, mask)
+			case "id", "unknown_and_wrong_type":
+				var invalid *errs.InvalidError
+				assert.True(t, errors.As(err, &invalid))
+				assert.Equal(t, testCase.expectedFields, invalid.Fields)
+			case "not_an_object", "malformed":
+				assert.True(t, errors.Is(err, errs.ErrInvalidContent), "%v", err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package http
+
+import (
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var listQueryParams = map[string]bool{
+	"limit":            true,
+	"cursor":           true,
+	"sort":             true,
+	"name":             true,
+	"work":             true,
+	"address_contains": true,
+	"age_min":          true,
+	"age_max":          true,
+	"as_of":            true,
+}
+
+var trashQueryParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+	"sort":   true,
+}
+
+var historyQueryParams = map[string]bool{
+	"limit":  true,
+	"cursor": true,
+}
+
+var searchQueryParams = map[string]bool{
+	"q":         true,
+	"mode":      true,
+	"threshold": true,
+	"limit":     true,
+	"cursor":    true,
+}
+
+var sortableFields = map[string]models.PersonField{
+	"id":      models.PersonFieldId,
+	"name":    models.PersonFieldName,
+	"address": models.PersonFieldAddress,
+	"work":    models.PersonFieldWork,
+	"age":     models.PersonFieldAge,
+}
+
+// cursorDTO keeps every sortable value of the last row, so the cursor stays
+// valid whatever sort the next page is requested with.
+type cursorDTO struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Work    string `json:"work"`
+	Age     int    `json:"age"`
+}
+
+func EncodeCursor(modelBL *models.Person) string {
+	if modelBL == nil {
+		return ""
+	}
+
+	raw, _ := json.Marshal(cursorDTO{
+		Id:      modelBL.Id,
+		Name:    modelBL.Name,
+		Address: modelBL.Address,
+		Work:    modelBL.Work,
+		Age:     modelBL.Age,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(cursor string) (*models.Person, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.ErrInvalidContent
+	}
+
+	dto := cursorDTO{}
+
+	err = json.Unmarshal(raw, &dto)
+	if err != nil || dto.Id <= 0 {
+		return nil, errs.ErrInvalidContent
+	}
+
+	return &models.Person{
+		Id:      dto.Id,
+		Name:    dto.Name,
+		Address: dto.Address,
+		Work:    dto.Work,
+		Age:     dto.Age,
+	}, nil
+}
+
+type hitCursorDTO struct {
+	Id   int     `json:"id"`
+	Rank float32 `json:"rank"`
+}
+
+func EncodeHitCursor(hit *models.PersonHit) string {
+	if hit == nil {
+		return ""
+	}
+
+	raw, _ := json.Marshal(hitCursorDTO{Id: hit.Person.Id, Rank: hit.Rank})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeHitCursor(cursor string) (*models.PersonHit, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.ErrInvalidContent
+	}
+
+	dto := hitCursorDTO{}
+
+	err = json.Unmarshal(raw, &dto)
+	if err != nil || dto.Id <= 0 {
+		return nil, errs.ErrInvalidContent
+	}
+
+	return &models.PersonHit{Person: &models.Person{Id: dto.Id}, Rank: dto.Rank}, nil
+}
+
+// ParsePage reads limit, cursor and sort query params. The second result
+// reports whether the client asked for pagination at all, a page it did not
+// ask for is still capped at maxPageLimit.
+func ParsePage(c *gin.Context) (*models.Page, bool, error) {
+	sort, err := ParseSort(c.Query("sort"))
+	if err != nil {
+		return nil, false, err
+	}
+
+	limitStr, hasLimit := c.GetQuery("limit")
+	cursor, hasCursor := c.GetQuery("cursor")
+
+	if !hasLimit && !hasCursor {
+		return &models.Page{Sort: sort, Limit: maxPageLimit}, false, nil
+	}
+
+	page := &models.Page{Sort: sort}
+
+	page.Limit, err = parseLimit(limitStr, hasLimit)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if hasCursor && cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return nil, true, errs.Invalid("cursor is malformed")
+		}
+
+		page.After = after
+	}
+
+	return page, true, nil
+}
+
+func EncodeRevisionCursor(rev *models.PersonRevision) string {
+	if rev == nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(rev.Revision, 10)))
+}
+
+func DecodeRevisionCursor(cursor string) (*models.PersonRevision, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.ErrInvalidContent
+	}
+
+	revision, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || revision <= 0 {
+		return nil, errs.ErrInvalidContent
+	}
+
+	return &models.PersonRevision{Revision: revision}, nil
+}
+
+// ParseHistoryPage reads limit and cursor query params, history is always
+// paginated.
+func ParseHistoryPage(c *gin.Context) (*models.HistoryPage, error) {
+	limitStr, hasLimit := c.GetQuery("limit")
+
+	limit, err := parseLimit(limitStr, hasLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.HistoryPage{Limit: limit}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := DecodeRevisionCursor(cursor)
+		if err != nil {
+			return nil, errs.Invalid("cursor is malformed")
+		}
+
+		page.After = after
+	}
+
+	return page, nil
+}
+
+// ParsePersonSearch reads q, mode and threshold query params, threshold
+// falls back to defaultThreshold and makes sense for the fuzzy mode only.
+func ParsePersonSearch(c *gin.Context, defaultThreshold float32) (*models.PersonSearch, error) {
+	search := &models.PersonSearch{
+		Query:     c.Query("q"),
+		Mode:      models.SearchMode(c.DefaultQuery("mode", string(models.SearchModeFullText))),
+		Threshold: defaultThreshold,
+	}
+
+	if strings.TrimSpace(search.Query) == "" {
+		return nil, errs.Invalid("q must not be empty")
+	}
+
+	if search.Mode != models.SearchModeFullText && search.Mode != models.SearchModeFuzzy {
+		return nil, errs.Invalid("mode must be %q or %q", models.SearchModeFullText, models.SearchModeFuzzy)
+	}
+
+	if str, ok := c.GetQuery("threshold"); ok {
+		threshold, err := strconv.ParseFloat(str, 32)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return nil, errs.Invalid("threshold must be a number in (0, 1]")
+		}
+
+		search.Threshold = float32(threshold)
+	}
+
+	return search, nil
+}
+
+// ParseSearchPage reads limit and cursor query params, search is always paginated.
+func ParseSearchPage(c *gin.Context) (*models.SearchPage, error) {
+	limitStr, hasLimit := c.GetQuery("limit")
+
+	limit, err := parseLimit(limitStr, hasLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.SearchPage{Limit: limit}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := DecodeHitCursor(cursor)
+		if err != nil {
+			return nil, errs.Invalid("cursor is malformed")
+		}
+
+		page.After = after
+	}
+
+	return page, nil
+}
+
+func parseLimit(str string, ok bool) (int, error) {
+	if !ok {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(str)
+	if err != nil || limit <= 0 || limit > maxPageLimit {
+		return 0, errs.Invalid("limit must be an integer from 1 to %d", maxPageLimit)
+	}
+
+	return limit, nil
+}
+
+// ParseSort reads a comma separated list of fields, "-" prefix means
+// descending order, e.g. "age,-name".
+func ParseSort(str string) ([]models.Sort, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(str, ",")
+	sort := make([]models.Sort, 0, len(parts))
+	seen := make(map[models.PersonField]bool, len(parts))
+
+	for _, part := range parts {
+		desc := strings.HasPrefix(part, "-")
+
+		field, ok := sortableFields[strings.TrimPrefix(part, "-")]
+		if !ok {
+			return nil, errs.Invalid("sort by %q is not supported", part)
+		}
+
+		if seen[field] {
+			return nil, errs.Invalid("sort field %q is repeated", field)
+		}
+		seen[field] = true
+
+		sort = append(sort, models.Sort{Field: field, Desc: desc})
+	}
+
+	return sort, nil
+}
+
+// CheckQueryParams rejects query params which are not in the allowed set.
+func CheckQueryParams(c *gin.Context, allowed map[string]bool) error {
+	for key := range c.Request.URL.Query() {
+		if !allowed[key] {
+			return errs.Invalid("unknown query param %q", key)
+		}
+	}
+
+	return nil
+}
+
+func ParsePersonFilter(c *gin.Context) (*models.PersonFilter, error) {
+	filter := &models.PersonFilter{}
+
+	if name, ok := c.GetQuery("name"); ok {
+		filter.Name = &name
+	}
+
+	if work, ok := c.GetQuery("work"); ok {
+		filter.Work = &work
+	}
+
+	if address, ok := c.GetQuery("address_contains"); ok {
+		filter.AddressContains = &address
+	}
+
+	ageMin, err := parseIntQuery(c, "age_min")
+	if err != nil {
+		return nil, err
+	}
+	filter.AgeMin = ageMin
+
+	ageMax, err := parseIntQuery(c, "age_max")
+	if err != nil {
+		return nil, err
+	}
+	filter.AgeMax = ageMax
+
+	if ageMin != nil && ageMax != nil && *ageMin > *ageMax {
+		return nil, errs.Invalid("age_min must not be greater than age_max")
+	}
+
+	filter.AsOf, err = ParseAsOf(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// ParseAsOf reads the as_of query param, an RFC 3339 instant.
+func ParseAsOf(c *gin.Context) (*time.Time, error) {
+	str, ok := c.GetQuery("as_of")
+	if !ok {
+		return nil, nil
+	}
+
+	asOf, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return nil, errs.Invalid("as_of must be an RFC 3339 time like 2024-03-01T00:00:00Z")
+	}
+
+	return &asOf, nil
+}
+
+func parseIntQuery(c *gin.Context, key string) (*int, error) {
+	str, ok := c.GetQuery(key)
+	if !ok {
+		return nil, nil
+	}
+
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return nil, errs.Invalid("%s must be an integer", key)
+	}
+
+	return &value, nil
+}
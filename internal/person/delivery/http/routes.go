@@ -1,15 +1,68 @@
 package http
 
 import (
+	"bmstu-dips-lab1/internal/middleware"
 	"bmstu-dips-lab1/internal/person"
+	"bmstu-dips-lab1/pkg/errs"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func MapPersonRoutes(personGroup *gin.RouterGroup, h person.Handlers) {
+func MapPersonRoutes(personGroup *gin.RouterGroup, h person.Handlers, mw *middleware.MiddlewareManager) {
 	personGroup.POST("", h.Create())
 	personGroup.DELETE("/:personid", h.Delete())
 	personGroup.PATCH("/:personid", h.Update())
+	personGroup.PUT("/:personid", h.Replace())
 	personGroup.GET("", h.GetAll())
+	personGroup.GET("/search", h.Search())
+	personGroup.GET("/trash", mw.UserRequiredMiddleware(), h.Trash())
+	personGroup.GET("/export.csv", h.ExportCSV())
+	personGroup.POST("/import", h.Import())
 	personGroup.GET("/:personid", h.GetById())
+	personGroup.GET("/:personid/history", mw.UserRequiredMiddleware(), h.History())
+	personGroup.POST("/:personid/revisions/:rev", Verbs("rev", map[string]gin.HandlerFunc{
+		"revert": h.Revert(),
+	}))
+	personGroup.POST("/:personid", Verbs("personid", map[string]gin.HandlerFunc{
+		"restore": h.Restore(),
+		"purge":   h.Purge(),
+	}))
+}
+
+// MapPersonVerbRoutes maps custom methods of the persons collection,
+// like "/persons:batch", onto the group which contains "/persons".
+func MapPersonVerbRoutes(group *gin.RouterGroup, h person.Handlers) {
+	group.POST("/persons:verb", Verbs("verb", map[string]gin.HandlerFunc{
+		"batch": h.CreateMany(),
+	}))
+}
+
+// Verbs dispatches custom methods like "/persons/{id}:restore". Gin has no
+// literal colons in routes, so the verb comes as a suffix of the param,
+// which is stripped before the verb handler runs.
+func Verbs(param string, handlers map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.Param(param)
+
+		i := strings.LastIndex(value, ":")
+		if i < 0 {
+			errs.Abort(c, errs.ErrNotFound)
+			return
+		}
+
+		handler, ok := handlers[value[i+1:]]
+		if !ok {
+			errs.Abort(c, errs.ErrNotFound)
+			return
+		}
+
+		for j := range c.Params {
+			if c.Params[j].Key == param {
+				c.Params[j].Value = value[:i]
+			}
+		}
+
+		handler(c)
+	}
 }
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/vcard"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// x-age keeps the age as is, vCard itself has only BDAY
+const vcardAge = "X-AGE"
+
+// PersonToCard maps name to FN and N, address to ADR, work to ORG.
+// N takes the last word of the name as the family name.
+func PersonToCard(modelBL *models.Person) vcard.Card {
+	name := strings.TrimSpace(modelBL.Name)
+
+	family, given := name, ""
+	if i := strings.LastIndexByte(name, ' '); i >= 0 {
+		given, family = strings.TrimSpace(name[:i]), name[i+1:]
+	}
+
+	return vcard.Card{
+		{Name: "FN", Value: []string{modelBL.Name}},
+		{Name: "N", Value: []string{family, given, "", "", ""}},
+		{Name: "ADR", Value: []string{"", "", modelBL.Address, "", "", "", ""}},
+		{Name: "ORG", Value: []string{modelBL.Work}},
+		{Name: vcardAge, Value: []string{strconv.Itoa(modelBL.Age)}},
+	}
+}
+
+// CardToPersonRequest is the reverse of PersonToCard. It falls back to N
+// when there is no FN and computes the age from BDAY when there is no X-AGE.
+func CardToPersonRequest(card vcard.Card) (*PersonCreatRequest, error) {
+	request := &PersonCreatRequest{
+		Name: strings.TrimSpace(card.Text("FN")),
+		Work: strings.TrimSpace(card.Text("ORG")),
+	}
+
+	if request.Name == "" {
+		if n := card.Get("N"); len(n) > 1 {
+			request.Name = strings.TrimSpace(n[1] + " " + n[0])
+		}
+	}
+
+	address := make([]string, 0)
+	for _, part := range card.Get("ADR") {
+		if part = strings.TrimSpace(part); part != "" {
+			address = append(address, part)
+		}
+	}
+	request.Address = strings.Join(address, ", ")
+
+	var err error
+
+	if age := card.Text(vcardAge); age != "" {
+		request.Age, err = strconv.Atoi(strings.TrimSpace(age))
+		if err != nil {
+			return nil, errors.New("X-AGE must be an integer")
+		}
+	} else if bday := card.Text("BDAY"); bday != "" {
+		request.Age, err = ageFromBirthday(strings.TrimSpace(bday), time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+func ageFromBirthday(bday string, now time.Time) (int, error) {
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		born, err := time.Parse(layout, bday)
+		if err != nil {
+			continue
+		}
+
+		age := now.Year() - born.Year()
+		if now.Month() < born.Month() || now.Month() == born.Month() && now.Day() < born.Day() {
+			age--
+		}
+
+		return age, nil
+	}
+
+	return 0, errors.New("BDAY must be a date like 19900131")
+}
+
+type personVCardSource struct {
+	d *vcard.Decoder
+}
+
+func NewPersonVCardSource(r io.Reader) models.PersonSource {
+	return &personVCardSource{d: vcard.NewDecoder(r)}
+}
+
+func (s *personVCardSource) Next() (*models.PersonRow, error) {
+	card, line, err := s.d.Decode()
+
+	var syntaxErr *vcard.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &models.PersonRow{Line: line, Err: err}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	row := &models.PersonRow{Line: line}
+
+	request, err := CardToPersonRequest(card)
+	if err != nil {
+		row.Err = err
+		return row, nil
+	}
+
+	row.Person = PersonCreatRequestToBL(request)
+
+	return row, nil
+}
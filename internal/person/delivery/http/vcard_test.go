@@ -0,0 +1,52 @@
+package http_test
+
+import (
+	h "bmstu-dips-lab1/internal/person/delivery/http"
+	"bmstu-dips-lab1/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonToCard_N(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest  string
+		name      string
+		expectedN []string
+	}{
+		{
+			nameTest:  "one_word",
+			name:      "Ivan",
+			expectedN: []string{"Ivan", "", "", "", ""},
+		},
+		{
+			nameTest:  "two_words",
+			name:      "Ivan Petrov",
+			expectedN: []string{"Petrov", "Ivan", "", "", ""},
+		},
+		{
+			nameTest:  "padded",
+			name:      "  Ivan Petrov  ",
+			expectedN: []string{"Petrov", "Ivan", "", "", ""},
+		},
+		{
+			nameTest:  "inner_spaces",
+			name:      "Ivan Ivanovich   Petrov",
+			expectedN: []string{"Petrov", "Ivan Ivanovich", "", "", ""},
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			t.Parallel()
+
+			card := h.PersonToCard(&models.Person{Name: testCase.name})
+
+			assert.Equal(t, testCase.expectedN, card.Get("N"))
+		})
+	}
+}
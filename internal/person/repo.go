@@ -3,12 +3,30 @@ package person
 import (
 	"bmstu-dips-lab1/models"
 	"context"
+	"time"
 )
 
 type Repo interface {
-	Create(ctx context.Context, modelBL *models.Person) (*models.Person, error)
+	Create(ctx context.Context, modelBL *models.Person, actor string) (*models.Person, error)
+	CreateMany(ctx context.Context, modelsBL []*models.Person, mode models.BatchMode, actor string) ([]*models.PersonResult, error)
+	Import(ctx context.Context, next func() (*models.Person, error), actor string) (int64, error)
 	GetById(ctx context.Context, id int) (*models.Person, error)
-	GetAll(ctx context.Context) ([]*models.Person, error)
-	Update(ctx context.Context, modelBL *models.Person, toUpdate *models.Person) (*models.Person, error)
-	Delete(ctx context.Context, id int) error
+	GetAsOf(ctx context.Context, id int, asOf time.Time) (*models.Person, error)
+	GetAll(ctx context.Context, filter *models.PersonFilter, page *models.Page) (*models.PersonPage, error)
+	Stat(ctx context.Context, filter *models.PersonFilter) (*models.PersonStat, error)
+	ForEach(ctx context.Context, filter *models.PersonFilter, sort []models.Sort, fn func(*models.Person) error) error
+	Search(ctx context.Context, search *models.PersonSearch, page *models.SearchPage) (*models.PersonHitPage, error)
+	Update(ctx context.Context, modelBL *models.Person, mask models.PersonMask, actor string) (*models.Person, error)
+	Replace(ctx context.Context, modelBL *models.Person, actor string) (*models.Person, error)
+	Revert(ctx context.Context, modelBL *models.Person, actor string) (*models.Person, error)
+	Upsert(ctx context.Context, modelBL *models.Person, createOnly bool, actor string) (*models.Person, bool, error)
+	Delete(ctx context.Context, id int, version int, actor string) error
+	Trash(ctx context.Context, page *models.Page) (*models.PersonPage, error)
+	Restore(ctx context.Context, id int, actor string) (*models.Person, error)
+	Purge(ctx context.Context, id int, actor string) error
+	History(ctx context.Context, id int, page *models.HistoryPage) (*models.PersonRevisionPage, error)
+	GetRevision(ctx context.Context, id int, rev int64) (*models.PersonRevision, error)
+	ReserveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
 }
@@ -5,15 +5,92 @@ import (
 	"bmstu-dips-lab1/models"
 	"bmstu-dips-lab1/pkg/errs"
 	"bmstu-dips-lab1/pkg/postgres"
+	"bmstu-dips-lab1/pkg/translit"
 	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx"
+	pgx4 "github.com/jackc/pgx/v4"
 )
 
 type PersonDB struct {
-	id, age             int
-	name, address, work string
+	id, age, version                int
+	name, address, work             string
+	createdAt, updatedAt, deletedAt time.Time
+}
+
+// personSnapshot is a person as persons_history_ keeps it
+type personSnapshot struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Work    string `json:"work"`
+	Age     int    `json:"age"`
+}
+
+// snapshotExpr builds the personSnapshot of a row written to persons_
+const snapshotExpr = "jsonb_build_object('name', name_, 'address', address_, 'work', work_, 'age', age_)"
+
+// writtenColumns are returned by a write for its revision to be recorded
+const writtenColumns = "id_, name_, address_, work_, age_, version_, created_at_, updated_at_"
+
+// expired idempotency keys removed by a single reservation
+const expiredKeysBatch = 100
+
+// nested builds the writes recorded wraps, their ? placeholders are
+// numbered by the statement around them
+var nested = squirrel.StatementBuilder
+
+// notDeleted keeps the persons in the trash out of reads and writes
+var notDeleted = squirrel.Eq{"deleted_at_": nil}
+
+var personColumns = map[models.PersonField]string{
+	models.PersonFieldId:      "id_",
+	models.PersonFieldName:    "name_",
+	models.PersonFieldAddress: "address_",
+	models.PersonFieldWork:    "work_",
+	models.PersonFieldAge:     "age_",
+}
+
+// querier is either the pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx4.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx4.Row
+}
+
+// personCopySource adapts a persons iterator to pgx.CopyFromSource
+type personCopySource struct {
+	next    func() (*models.Person, error)
+	current *PersonDB
+	err     error
+}
+
+func (s *personCopySource) Next() bool {
+	modelBL, err := s.next()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+
+		return false
+	}
+
+	s.current, s.err = PersonBLToDB(modelBL)
+
+	return s.err == nil
+}
+
+func (s *personCopySource) Values() ([]interface{}, error) {
+	return []interface{}{s.current.name, s.current.address, s.current.work, s.current.age}, nil
+}
+
+func (s *personCopySource) Err() error {
+	return s.err
 }
 
 type PersonRepo struct {
@@ -24,23 +101,84 @@ func NewPersonRepo(db *postgres.Postgres) person.Repo {
 	return &PersonRepo{db}
 }
 
-func (p *PersonRepo) Create(ctx context.Context, modelBL *models.Person) (*models.Person, error) {
+func (p *PersonRepo) Create(ctx context.Context, modelBL *models.Person, actor string) (*models.Person, error) {
+	return p.insert(ctx, p.Pool, modelBL, actor)
+}
+
+// CreateMany inserts persons in one transaction. In the best effort mode
+// every insert runs in its own savepoint, so a failed row does not abort
+// the rest of them.
+func (p *PersonRepo) CreateMany(ctx context.Context, modelsBL []*models.Person, mode models.BatchMode, actor string) ([]*models.PersonResult, error) {
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	res := make([]*models.PersonResult, len(modelsBL))
+
+	for i, modelBL := range modelsBL {
+		if mode == models.BatchModeAtomic {
+			created, err := p.insert(ctx, tx, modelBL, actor)
+			if err != nil {
+				return nil, err
+			}
+
+			res[i] = &models.PersonResult{Person: created}
+			continue
+		}
+
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		created, err := p.insert(ctx, savepoint, modelBL, actor)
+		if err != nil {
+			if rbErr := savepoint.Rollback(ctx); rbErr != nil {
+				return nil, rbErr
+			}
+
+			res[i] = &models.PersonResult{Err: err}
+			continue
+		}
+
+		err = savepoint.Commit(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		res[i] = &models.PersonResult{Person: created}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (p *PersonRepo) insert(ctx context.Context, q querier, modelBL *models.Person, actor string) (*models.Person, error) {
 	modelDB, err := PersonBLToDB(modelBL)
 	if err != nil {
 		return nil, errs.ErrInvalidContent
 	}
 
-	sql, args, err := p.Builder.
+	write := nested.
 		Insert("persons_").
 		Columns("name_, address_, work_, age_").
 		Values(modelDB.name, modelDB.address, modelDB.work, modelDB.age).
-		Suffix("RETURNING \"id_\"").
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(models.PersonOperationCreate), actor, snapshotExpr), "id_, version_, created_at_, updated_at_").
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.id)
+	err = q.QueryRow(ctx, sql, args...).Scan(&modelDB.id, &modelDB.version, &modelDB.createdAt, &modelDB.updatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -48,18 +186,77 @@ func (p *PersonRepo) Create(ctx context.Context, modelBL *models.Person) (*model
 	return PersonDBToBL(modelDB)
 }
 
+// Import streams persons returned by next with COPY into a staging table
+// until next returns io.EOF, then moves them to persons_ in the order they
+// came along with their revisions. Nothing is imported when any other error
+// occurs.
+func (p *PersonRepo) Import(ctx context.Context, next func() (*models.Person, error), actor string) (int64, error) {
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "CREATE TEMPORARY TABLE persons_import_ "+
+		"(n_ SERIAL, name_ VARCHAR(64), address_ VARCHAR(64), work_ VARCHAR(64), age_ INT) ON COMMIT DROP")
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx4.Identifier{"persons_import_"},
+		[]string{"name_", "address_", "work_", "age_"},
+		&personCopySource{next: next},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	write := nested.
+		Insert("persons_").
+		Columns("name_, address_, work_, age_").
+		Select(nested.
+			Select("name_, address_, work_, age_").
+			From("persons_import_").
+			OrderBy("n_")).
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(models.PersonOperationCreate), actor, snapshotExpr), "count(*)").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var imported int64
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&imported)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return imported, nil
+}
+
 func (p *PersonRepo) GetById(ctx context.Context, id int) (*models.Person, error) {
 	sql, args, err := p.Builder.
-		Select("name_, address_, work_, age_").
+		Select("name_, address_, work_, age_, version_, created_at_, updated_at_").
 		From("persons_").
 		Where(squirrel.Eq{"id_": id}).
+		Where(notDeleted).
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
 	modelDB := PersonDB{id: id}
-	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age)
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age,
+		&modelDB.version, &modelDB.createdAt, &modelDB.updatedAt)
 	if err != nil {
 		if err.Error() == pgx.ErrNoRows.Error() {
 			return nil, errs.ErrNotFound
@@ -71,27 +268,179 @@ func (p *PersonRepo) GetById(ctx context.Context, id int) (*models.Person, error
 	return PersonDBToBL(&modelDB)
 }
 
-func (p *PersonRepo) GetAll(ctx context.Context) ([]*models.Person, error) {
+func (p *PersonRepo) GetAll(ctx context.Context, filter *models.PersonFilter, page *models.Page) (*models.PersonPage, error) {
+	builder := p.selectPersons("id_, name_, address_, work_, age_, version_, updated_at_", filter)
+
+	return p.selectPage(ctx, builder, page, false)
+}
+
+// GetRevision finds revision rev of the person, a revision of another
+// person is errs.ErrNotFound.
+func (p *PersonRepo) GetRevision(ctx context.Context, id int, rev int64) (*models.PersonRevision, error) {
 	sql, args, err := p.Builder.
-		Select("id_, name_, address_, work_, age_").
-		From("persons_").
+		Select("version_, operation_, actor_, changed_at_, after_").
+		From("persons_history_").
+		Where(squirrel.Eq{"id_": rev, "person_id_": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &models.PersonRevision{Revision: rev, PersonId: id}
+	var operation string
+	var after []byte
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&revision.Version, &operation, &revision.Actor, &revision.ChangedAt, &after)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, errs.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	revision.Operation = models.PersonOperation(operation)
+
+	revision.After, err = unmarshalSnapshot(id, after)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// GetAsOf rebuilds the person from the last revision made by asOf. A person
+// not created yet or deleted then is errs.ErrNotFound.
+func (p *PersonRepo) GetAsOf(ctx context.Context, id int, asOf time.Time) (*models.Person, error) {
+	sql, args, err := p.Builder.
+		Select("version_, changed_at_, after_").
+		From("persons_history_").
+		Where(squirrel.Eq{"person_id_": id}).
+		Where(squirrel.LtOrEq{"changed_at_": asOf}).
+		OrderBy("id_ DESC").
+		Limit(1).
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
+	var version int
+	var changedAt time.Time
+	var after []byte
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&version, &changedAt, &after)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, errs.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	modelBL, err := unmarshalSnapshot(id, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if modelBL == nil {
+		return nil, errs.ErrNotFound
+	}
+
+	modelBL.Version = version
+	modelBL.UpdatedAt = changedAt
+
+	return modelBL, nil
+}
+
+// selectPersons selects columns of the persons filter matches. With
+// filter.AsOf the persons come from their history rather than persons_,
+// under the same column names, updated_at_ being the time of the revision.
+func (p *PersonRepo) selectPersons(columns string, filter *models.PersonFilter) squirrel.SelectBuilder {
+	builder := p.Builder.
+		Select(columns)
+
+	if filter != nil && filter.AsOf != nil {
+		revisions := p.Builder.
+			Select("DISTINCT ON (person_id_) person_id_, version_, changed_at_, after_").
+			From("persons_history_").
+			Where(squirrel.LtOrEq{"changed_at_": *filter.AsOf}).
+			OrderBy("person_id_", "id_ DESC")
+
+		snapshot := p.Builder.
+			Select("person_id_ AS id_, after_->>'name' AS name_, after_->>'address' AS address_, "+
+				"after_->>'work' AS work_, (after_->>'age')::int AS age_, version_, changed_at_ AS updated_at_").
+			FromSelect(revisions, "revisions_").
+			Where(squirrel.NotEq{"after_": nil})
+
+		builder = builder.
+			FromSelect(snapshot, "persons_")
+	} else {
+		builder = builder.
+			From("persons_").
+			Where(notDeleted)
+	}
+
+	return WherePersonFilter(builder, filter)
+}
+
+// Trash pages over the soft deleted persons, each with its deletion time.
+func (p *PersonRepo) Trash(ctx context.Context, page *models.Page) (*models.PersonPage, error) {
+	builder := p.Builder.
+		Select("id_, name_, address_, work_, age_, version_, updated_at_, deleted_at_").
+		From("persons_").
+		Where(squirrel.NotEq{"deleted_at_": nil})
+
+	return p.selectPage(ctx, builder, page, true)
+}
+
+// selectPage orders builder by page and takes a page of it. The builder
+// selects id_, name_, address_, work_, age_, version_ and updated_at_,
+// followed by deleted_at_ when trashed is set.
+func (p *PersonRepo) selectPage(ctx context.Context, builder squirrel.SelectBuilder, page *models.Page, trashed bool) (*models.PersonPage, error) {
+	order, err := PersonOrder(page.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.After != nil {
+		builder = builder.
+			Where(AfterKeyset(order, page.After))
+	}
+
+	builder = builder.
+		OrderBy(OrderByClauses(order)...)
+
+	if page.Limit > 0 {
+		// one extra row tells whether the next page exists
+		builder = builder.
+			Limit(uint64(page.Limit + 1))
+	}
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := p.Pool.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	res := make([]*models.Person, 0)
+	res := &models.PersonPage{
+		Persons: make([]*models.Person, 0),
+	}
 
 	for rows.Next() {
 		modelDB := PersonDB{}
+		dest := []interface{}{&modelDB.id, &modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age,
+			&modelDB.version, &modelDB.updatedAt}
 
-		err = rows.Scan(&modelDB.id, &modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age)
+		if trashed {
+			dest = append(dest, &modelDB.deletedAt)
+		}
+
+		err = rows.Scan(dest...)
 		if err != nil {
 			return nil, err
 		}
@@ -101,94 +450,971 @@ func (p *PersonRepo) GetAll(ctx context.Context) ([]*models.Person, error) {
 			return nil, err
 		}
 
-		res = append(res, formBL)
+		res.Persons = append(res.Persons, formBL)
+	}
+
+	if page.Limit > 0 && len(res.Persons) > page.Limit {
+		res.Persons = res.Persons[:page.Limit]
+		res.Next = res.Persons[page.Limit-1]
 	}
 
 	return res, nil
 }
 
-func (p *PersonRepo) Update(ctx context.Context, modelBL *models.Person, toUpdate *models.Person) (*models.Person, error) {
-	modelDB, err := PersonBLToDB(modelBL)
+// Stat counts the persons filter matches and finds their last change.
+func (p *PersonRepo) Stat(ctx context.Context, filter *models.PersonFilter) (*models.PersonStat, error) {
+	sql, args, err := p.selectPersons("count(*), max(updated_at_)", filter).ToSql()
 	if err != nil {
-		return nil, errs.ErrInvalidContent
+		return nil, err
 	}
 
-	builder := p.Builder.
-		Update("persons_")
+	stat := &models.PersonStat{}
+	var lastModified *time.Time
 
-	if toUpdate.Name != "" {
-		builder = builder.
-			Set("name_", modelDB.name)
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&stat.Count, &lastModified)
+	if err != nil {
+		return nil, err
 	}
 
-	if toUpdate.Address != "" {
-		builder = builder.
-			Set("address_", modelDB.address)
+	if lastModified != nil {
+		stat.LastModified = *lastModified
 	}
 
-	if toUpdate.Work != "" {
-		builder = builder.
-			Set("work_", modelDB.work)
-	}
+	return stat, nil
+}
 
-	if toUpdate.Age != 0 {
-		builder = builder.
-			Set("age_", modelDB.age)
+// ForEach calls fn for every person straight from the rows cursor, so memory
+// use does not depend on the table size. An error of fn stops the iteration
+// and is returned as is.
+func (p *PersonRepo) ForEach(ctx context.Context, filter *models.PersonFilter, sort []models.Sort, fn func(*models.Person) error) error {
+	order, err := PersonOrder(sort)
+	if err != nil {
+		return err
 	}
 
-	sql, args, err := builder.
-		Where(squirrel.Eq{"id_": modelDB.id}).
+	sql, args, err := p.selectPersons("id_, name_, address_, work_, age_", filter).
+		OrderBy(OrderByClauses(order)...).
 		ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := p.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		modelDB := PersonDB{}
+
+		err = rows.Scan(&modelDB.id, &modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age)
+		if err != nil {
+			return err
+		}
+
+		formBL, err := PersonDBToBL(&modelDB)
+		if err != nil {
+			return err
+		}
+
+		err = fn(formBL)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (p *PersonRepo) Search(ctx context.Context, search *models.PersonSearch, page *models.SearchPage) (*models.PersonHitPage, error) {
+	var hits squirrel.SelectBuilder
+
+	switch search.Mode {
+	case models.SearchModeFuzzy:
+		variants := translit.Variants(search.Query)
+		if variants[0] == "" || search.Threshold <= 0 || search.Threshold > 1 {
+			return nil, errs.ErrInvalidContent
+		}
+
+		similarity, args := SimilarityExpr("name_", variants)
+		match, matchArgs := TrigramMatchExpr("name_", variants)
+
+		hits = p.Builder.
+			Select("id_, name_, address_, work_, age_").
+			Column(squirrel.Expr(similarity+" AS rank_", args...)).
+			From("persons_").
+			Where(notDeleted).
+			Where(squirrel.Expr(match, matchArgs...))
+
+		return p.searchFuzzy(ctx, hits, search.Threshold, page)
+	case models.SearchModeFullText, "":
+		tsQuery := TsQuery(search.Query)
+		if tsQuery == "" {
+			return nil, errs.ErrInvalidContent
+		}
+
+		hits = p.Builder.
+			Select("id_, name_, address_, work_, age_").
+			Column(squirrel.Expr("ts_rank(search_, to_tsquery('simple', ?)) AS rank_", tsQuery)).
+			From("persons_").
+			Where(notDeleted).
+			Where(squirrel.Expr("search_ @@ to_tsquery('simple', ?)", tsQuery))
+	default:
+		return nil, errs.ErrInvalidContent
+	}
+
+	return p.selectHits(ctx, p.Pool, hits, page)
+}
+
+// searchFuzzy selects trigram hits in a transaction of its own: the %
+// operator, unlike similarity(), is served by the trigram index, but it
+// takes the threshold from pg_trgm.similarity_threshold.
+func (p *PersonRepo) searchFuzzy(ctx context.Context, hits squirrel.SelectBuilder, threshold float32, page *models.SearchPage) (*models.PersonHitPage, error) {
+	tx, err := p.Pool.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	res, err := p.Pool.Exec(ctx, sql, args...)
+	_, err = tx.Exec(ctx, "SELECT set_config('pg_trgm.similarity_threshold', $1, true)",
+		strconv.FormatFloat(float64(threshold), 'f', -1, 32))
 	if err != nil {
 		return nil, err
 	}
 
-	if res.RowsAffected() == 0 {
-		return nil, errs.ErrNoContent
+	res, err := p.selectHits(ctx, tx, hits, page)
+	if err != nil {
+		return nil, err
 	}
 
-	return modelBL, nil
+	return res, tx.Commit(ctx)
 }
 
-func (p *PersonRepo) Delete(ctx context.Context, id int) error {
-	sql, args, err := p.Builder.
-		Delete("persons_").
-		Where(squirrel.Eq{"id_": id}).
-		ToSql()
+// selectHits pages over a hits subquery with id_, name_, address_, work_,
+// age_ and rank_ columns, best rank first.
+func (p *PersonRepo) selectHits(ctx context.Context, q querier, hits squirrel.SelectBuilder, page *models.SearchPage) (*models.PersonHitPage, error) {
+	builder := p.Builder.
+		Select("id_, name_, address_, work_, age_, rank_").
+		FromSelect(hits, "hits_")
+
+	if page.After != nil {
+		builder = builder.
+			Where(squirrel.Or{
+				squirrel.Lt{"rank_": page.After.Rank},
+				squirrel.And{
+					squirrel.Eq{"rank_": page.After.Rank},
+					squirrel.Gt{"id_": page.After.Person.Id},
+				},
+			})
+	}
+
+	builder = builder.
+		OrderBy("rank_ DESC", "id_")
+
+	if page.Limit > 0 {
+		builder = builder.
+			Limit(uint64(page.Limit + 1))
+	}
+
+	sql, args, err := builder.ToSql()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	res, err := p.Pool.Exec(ctx, sql, args...)
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	if res.RowsAffected() == 0 {
-		return errs.ErrNoContent
+	res := &models.PersonHitPage{
+		Hits: make([]*models.PersonHit, 0),
 	}
 
-	return nil
+	for rows.Next() {
+		modelDB := PersonDB{}
+		var rank float32
+
+		err = rows.Scan(&modelDB.id, &modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age, &rank)
+		if err != nil {
+			return nil, err
+		}
+
+		formBL, err := PersonDBToBL(&modelDB)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Hits = append(res.Hits, &models.PersonHit{Person: formBL, Rank: rank})
+	}
+
+	if page.Limit > 0 && len(res.Hits) > page.Limit {
+		res.Hits = res.Hits[:page.Limit]
+		res.Next = res.Hits[page.Limit-1]
+	}
+
+	return res, nil
+}
+
+// Update writes the fields of mask and gives the person as it is after
+// the write.
+func (p *PersonRepo) Update(ctx context.Context, modelBL *models.Person, mask models.PersonMask, actor string) (*models.Person, error) {
+	modelDB, err := PersonBLToDB(modelBL)
+	if err != nil {
+		return nil, errs.ErrInvalidContent
+	}
+
+	builder := nested.
+		Update("persons_")
+
+	if mask.Has(models.PersonFieldName) {
+		builder = builder.
+			Set("name_", modelDB.name)
+	}
+
+	if mask.Has(models.PersonFieldAddress) {
+		builder = builder.
+			Set("address_", modelDB.address)
+	}
+
+	if mask.Has(models.PersonFieldWork) {
+		builder = builder.
+			Set("work_", modelDB.work)
+	}
+
+	if mask.Has(models.PersonFieldAge) {
+		builder = builder.
+			Set("age_", modelDB.age)
+	}
+
+	write := builder.
+		Set("version_", squirrel.Expr("version_ + 1")).
+		Set("updated_at_", squirrel.Expr("now()")).
+		Where(versionedId(modelDB)).
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(models.PersonOperationUpdate), actor, snapshotExpr), "name_, address_, work_, age_, version_, created_at_, updated_at_").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age,
+		&modelDB.version, &modelDB.createdAt, &modelDB.updatedAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, p.missedWrite(ctx, versionedId(modelDB), errs.ErrNoContent)
+		}
+
+		return nil, err
+	}
+
+	return PersonDBToBL(modelDB)
+}
+
+// Replace overwrites every field of an existing person and moves its
+// version on.
+func (p *PersonRepo) Replace(ctx context.Context, modelBL *models.Person, actor string) (*models.Person, error) {
+	return p.overwrite(ctx, modelBL, models.PersonOperationUpdate, actor)
+}
+
+// Revert is Replace that takes a person in the trash out of it as well.
+func (p *PersonRepo) Revert(ctx context.Context, modelBL *models.Person, actor string) (*models.Person, error) {
+	return p.overwrite(ctx, modelBL, models.PersonOperationRevert, actor)
+}
+
+func (p *PersonRepo) overwrite(ctx context.Context, modelBL *models.Person, operation models.PersonOperation, actor string) (*models.Person, error) {
+	modelDB, err := PersonBLToDB(modelBL)
+	if err != nil {
+		return nil, errs.ErrInvalidContent
+	}
+
+	where := versionedId(modelDB)
+
+	builder := nested.
+		Update("persons_").
+		Set("name_", modelDB.name).
+		Set("address_", modelDB.address).
+		Set("work_", modelDB.work).
+		Set("age_", modelDB.age)
+
+	if operation == models.PersonOperationRevert {
+		delete(where, "deleted_at_")
+
+		builder = builder.
+			Set("deleted_at_", nil)
+	}
+
+	write := builder.
+		Set("version_", squirrel.Expr("version_ + 1")).
+		Set("updated_at_", squirrel.Expr("now()")).
+		Where(where).
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(operation), actor, snapshotExpr), "version_, created_at_, updated_at_").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.version, &modelDB.createdAt, &modelDB.updatedAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, p.missedWrite(ctx, where, errs.ErrNotFound)
+		}
+
+		return nil, err
+	}
+
+	return PersonDBToBL(modelDB)
+}
+
+// Upsert is Replace that creates the person with the given id when there is
+// none and reports whether it did. The id sequence is moved past the new id,
+// so that later inserts do not collide with it. A person in the trash is
+// neither replaced nor created anew, which is errs.ErrConflict. With
+// createOnly an existing person, in the trash or not, is left as it is,
+// which is errs.ErrPreconditionFailed.
+func (p *PersonRepo) Upsert(ctx context.Context, modelBL *models.Person, createOnly bool, actor string) (*models.Person, bool, error) {
+	modelDB, err := PersonBLToDB(modelBL)
+	if err != nil {
+		return nil, false, errs.ErrInvalidContent
+	}
+
+	conflict := "ON CONFLICT (id_) DO UPDATE SET " +
+		"name_ = EXCLUDED.name_, address_ = EXCLUDED.address_, work_ = EXCLUDED.work_, age_ = EXCLUDED.age_, " +
+		"version_ = persons_.version_ + 1, updated_at_ = now() " +
+		"WHERE persons_.deleted_at_ IS NULL "
+	if createOnly {
+		conflict = "ON CONFLICT (id_) DO NOTHING "
+	}
+
+	write := nested.
+		Insert("persons_").
+		Columns("id_, name_, address_, work_, age_").
+		Values(modelDB.id, modelDB.name, modelDB.address, modelDB.work, modelDB.age).
+		Suffix(conflict + "RETURNING (xmax = 0) AS created_, " + writtenColumns)
+
+	operation := squirrel.Expr("CASE WHEN created_ THEN ? ELSE ? END",
+		string(models.PersonOperationCreate), string(models.PersonOperationUpdate))
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operation, actor, snapshotExpr), "created_, version_, created_at_, updated_at_").
+		ToSql()
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var created bool
+
+	err = tx.QueryRow(ctx, sql, args...).Scan(&created, &modelDB.version, &modelDB.createdAt, &modelDB.updatedAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			if createOnly {
+				return nil, false, errs.ErrPreconditionFailed
+			}
+
+			return nil, false, errs.ErrConflict
+		}
+
+		return nil, false, err
+	}
+
+	if created {
+		_, err = tx.Exec(ctx, "SELECT setval(pg_get_serial_sequence('persons_', 'id_'), GREATEST(nextval(pg_get_serial_sequence('persons_', 'id_')), $1))", modelDB.id)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	upserted, err := PersonDBToBL(modelDB)
+
+	return upserted, created, err
+}
+
+// Delete moves the person to the trash, only in its version when version is
+// not zero. The row stays until Purge.
+func (p *PersonRepo) Delete(ctx context.Context, id int, version int, actor string) error {
+	modelDB := &PersonDB{id: id, version: version}
+
+	write := nested.
+		Update("persons_").
+		Set("deleted_at_", squirrel.Expr("now()")).
+		Set("version_", squirrel.Expr("version_ + 1")).
+		Set("updated_at_", squirrel.Expr("now()")).
+		Where(versionedId(modelDB)).
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(models.PersonOperationDelete), actor, "NULL"), "id_").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&id)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return p.missedWrite(ctx, versionedId(modelDB), errs.ErrNoContent)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Restore takes the person out of the trash.
+func (p *PersonRepo) Restore(ctx context.Context, id int, actor string) (*models.Person, error) {
+	write := nested.
+		Update("persons_").
+		Set("deleted_at_", nil).
+		Set("version_", squirrel.Expr("version_ + 1")).
+		Set("updated_at_", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id_": id}).
+		Where(squirrel.NotEq{"deleted_at_": nil}).
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(models.PersonOperationRestore), actor, snapshotExpr), "name_, address_, work_, age_, version_, created_at_, updated_at_").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	modelDB := PersonDB{id: id}
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.name, &modelDB.address, &modelDB.work, &modelDB.age,
+		&modelDB.version, &modelDB.createdAt, &modelDB.updatedAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, errs.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return PersonDBToBL(&modelDB)
+}
+
+// Purge removes the person from the trash for good, persons that are not
+// in the trash are errs.ErrNotFound.
+func (p *PersonRepo) Purge(ctx context.Context, id int, actor string) error {
+	write := nested.
+		Delete("persons_").
+		Where(squirrel.Eq{"id_": id}).
+		Where(squirrel.NotEq{"deleted_at_": nil}).
+		Suffix("RETURNING " + writtenColumns)
+
+	sql, args, err := p.
+		recorded(write, revisionOf(operationOf(models.PersonOperationPurge), actor, "NULL"), "id_").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&id)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return errs.ErrNotFound
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// recorded is write, a statement returning the writtenColumns of the
+// persons it writes as person_, with their revisions added to
+// persons_history_ by the same statement, so that neither is there
+// without the other. It selects columns of the written persons.
+func (p *PersonRepo) recorded(write squirrel.Sqlizer, revision squirrel.InsertBuilder, columns string) squirrel.SelectBuilder {
+	return p.Builder.
+		Select(columns).
+		From("person_").
+		PrefixExpr(squirrel.Expr("WITH person_ AS (?), revision_ AS (?)", write, revision))
+}
+
+// revisionOf inserts the revisions of the person_ rows, after is the
+// snapshot of a row or NULL when the write removed it. The time of the
+// write is the time of the revision.
+func revisionOf(operation squirrel.Sqlizer, actor string, after string) squirrel.InsertBuilder {
+	return nested.
+		Insert("persons_history_").
+		Columns("person_id_, version_, operation_, actor_, after_").
+		Select(nested.
+			Select("id_, version_").
+			Column(operation).
+			Column("?", actor).
+			Column(after).
+			From("person_"))
+}
+
+func operationOf(operation models.PersonOperation) squirrel.Sqlizer {
+	return squirrel.Expr("?", string(operation))
+}
+
+// History pages over the revisions of a person, newest first. Before of a
+// revision is the After of the one preceding it.
+func (p *PersonRepo) History(ctx context.Context, id int, page *models.HistoryPage) (*models.PersonRevisionPage, error) {
+	revisions := p.Builder.
+		Select("id_, version_, operation_, actor_, changed_at_").
+		Column("lag(after_) OVER (ORDER BY id_) AS before_").
+		Column("after_").
+		From("persons_history_").
+		Where(squirrel.Eq{"person_id_": id})
+
+	builder := p.Builder.
+		Select("id_, version_, operation_, actor_, changed_at_, before_, after_").
+		FromSelect(revisions, "history_")
+
+	if page.After != nil {
+		builder = builder.
+			Where(squirrel.Lt{"id_": page.After.Revision})
+	}
+
+	builder = builder.
+		OrderBy("id_ DESC")
+
+	if page.Limit > 0 {
+		builder = builder.
+			Limit(uint64(page.Limit + 1))
+	}
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &models.PersonRevisionPage{
+		Revisions: make([]*models.PersonRevision, 0),
+	}
+
+	for rows.Next() {
+		rev := &models.PersonRevision{PersonId: id}
+		var operation string
+		var before, after []byte
+
+		err = rows.Scan(&rev.Revision, &rev.Version, &operation, &rev.Actor, &rev.ChangedAt, &before, &after)
+		if err != nil {
+			return nil, err
+		}
+
+		rev.Operation = models.PersonOperation(operation)
+
+		rev.Before, err = unmarshalSnapshot(id, before)
+		if err != nil {
+			return nil, err
+		}
+
+		rev.After, err = unmarshalSnapshot(id, after)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Revisions = append(res.Revisions, rev)
+	}
+
+	if page.Limit > 0 && len(res.Revisions) > page.Limit {
+		res.Revisions = res.Revisions[:page.Limit]
+		res.Next = res.Revisions[page.Limit-1]
+	}
+
+	return res, nil
+}
+
+func unmarshalSnapshot(id int, raw []byte) (*models.Person, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	snapshot := personSnapshot{}
+
+	err := json.Unmarshal(raw, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Person{
+		Id:      id,
+		Name:    snapshot.Name,
+		Address: snapshot.Address,
+		Work:    snapshot.Work,
+		Age:     snapshot.Age,
+	}, nil
+}
+
+// ReserveIdempotencyKey stores key as in flight unless an unexpired key
+// of the same actor with the same name is there already, which is returned
+// instead. An
+// expired key is taken over as a fresh one, and a few other expired keys
+// are removed on the way so that the table does not outgrow the TTL.
+func (p *PersonRepo) ReserveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	err := p.deleteExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sql, args, err := p.Builder.
+		Insert("idempotency_keys_").
+		Columns("actor_, key_, request_hash_, expires_at_").
+		Values(key.Actor, key.Key, key.RequestHash, key.ExpiresAt).
+		Suffix("ON CONFLICT (actor_, key_) DO UPDATE SET " +
+			"request_hash_ = EXCLUDED.request_hash_, status_ = 0, location_ = '', etag_ = '', expires_at_ = EXCLUDED.expires_at_ " +
+			"WHERE idempotency_keys_.expires_at_ <= now() " +
+			"RETURNING \"key_\"").
+		ToSql()
+	if err != nil {
+		return nil, false, err
+	}
+
+	var reserved string
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&reserved)
+	if err == nil {
+		return key, true, nil
+	}
+
+	if err.Error() != pgx.ErrNoRows.Error() {
+		return nil, false, err
+	}
+
+	sql, args, err = p.Builder.
+		Select("request_hash_, status_, location_, etag_, expires_at_").
+		From("idempotency_keys_").
+		Where(squirrel.Eq{"actor_": key.Actor, "key_": key.Key}).
+		ToSql()
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored := &models.IdempotencyKey{Actor: key.Actor, Key: key.Key}
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&stored.RequestHash, &stored.Status, &stored.Location, &stored.ETag, &stored.ExpiresAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			// released by its request in between
+			return nil, false, errs.ErrConflict
+		}
+
+		return nil, false, err
+	}
+
+	return stored, false, nil
+}
+
+// deleteExpiredIdempotencyKeys removes up to expiredKeysBatch expired
+// keys. Every reservation removes more than it adds, which keeps up with
+// any rate of requests and takes a bounded time.
+func (p *PersonRepo) deleteExpiredIdempotencyKeys(ctx context.Context) error {
+	expired := p.Builder.
+		Select("actor_, key_").
+		From("idempotency_keys_").
+		Where(squirrel.Expr("expires_at_ < now()")).
+		Limit(expiredKeysBatch)
+
+	sql, args, err := p.Builder.
+		Delete("idempotency_keys_").
+		Where(squirrel.Expr("(actor_, key_) IN (?)", expired)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Pool.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// CompleteIdempotencyKey saves the response of the request key was
+// reserved for.
+func (p *PersonRepo) CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	sql, args, err := p.Builder.
+		Update("idempotency_keys_").
+		Set("status_", key.Status).
+		Set("location_", key.Location).
+		Set("etag_", key.ETag).
+		Where(squirrel.Eq{"actor_": key.Actor, "key_": key.Key}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Pool.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// ReleaseIdempotencyKey drops the key of a request that failed, so that
+// it can be retried with the same key.
+func (p *PersonRepo) ReleaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	sql, args, err := p.Builder.
+		Delete("idempotency_keys_").
+		Where(squirrel.Eq{"actor_": key.Actor, "key_": key.Key, "status_": 0}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.Pool.Exec(ctx, sql, args...)
+
+	return err
+}
+
+// versionedId matches the row of modelDB out of the trash, only in its
+// version when the version is set.
+func versionedId(modelDB *PersonDB) squirrel.Eq {
+	if modelDB.version == 0 {
+		return squirrel.Eq{"id_": modelDB.id, "deleted_at_": nil}
+	}
+
+	return squirrel.Eq{"id_": modelDB.id, "version_": modelDB.version, "deleted_at_": nil}
+}
+
+// missedWrite tells why a write of the person matched by where touched no
+// row: the person is missing, which is reported as notFound, or its version
+// has moved on. The person is looked for as the write did, in any version.
+func (p *PersonRepo) missedWrite(ctx context.Context, where squirrel.Eq, notFound error) error {
+	if _, versioned := where["version_"]; !versioned {
+		return notFound
+	}
+
+	exists := squirrel.Eq{}
+	for column, value := range where {
+		if column != "version_" {
+			exists[column] = value
+		}
+	}
+
+	sql, args, err := p.Builder.
+		Select("1").
+		From("persons_").
+		Where(exists).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var found int
+
+	err = p.Pool.QueryRow(ctx, sql, args...).Scan(&found)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return notFound
+		}
+
+		return err
+	}
+
+	return errs.ErrPreconditionFailed
+}
+
+func WherePersonFilter(builder squirrel.SelectBuilder, filter *models.PersonFilter) squirrel.SelectBuilder {
+	if filter == nil {
+		return builder
+	}
+
+	if filter.Name != nil {
+		builder = builder.
+			Where(squirrel.Eq{"name_": *filter.Name})
+	}
+
+	if filter.Work != nil {
+		builder = builder.
+			Where(squirrel.Eq{"work_": *filter.Work})
+	}
+
+	if filter.AddressContains != nil {
+		builder = builder.
+			Where(squirrel.ILike{"address_": "%" + EscapeLike(*filter.AddressContains) + "%"})
+	}
+
+	if filter.AgeMin != nil {
+		builder = builder.
+			Where(squirrel.GtOrEq{"age_": *filter.AgeMin})
+	}
+
+	if filter.AgeMax != nil {
+		builder = builder.
+			Where(squirrel.LtOrEq{"age_": *filter.AgeMax})
+	}
+
+	return builder
+}
+
+// PersonOrder validates sort fields and appends the id tie-break so that
+// ordering is total and keyset pagination is stable.
+func PersonOrder(sort []models.Sort) ([]models.Sort, error) {
+	order := make([]models.Sort, 0, len(sort)+1)
+	seen := make(map[models.PersonField]bool, len(sort))
+
+	for _, s := range sort {
+		if _, ok := personColumns[s.Field]; !ok || seen[s.Field] {
+			return nil, errs.ErrInvalidContent
+		}
+
+		seen[s.Field] = true
+		order = append(order, s)
+
+		if s.Field == models.PersonFieldId {
+			return order, nil
+		}
+	}
+
+	return append(order, models.Sort{Field: models.PersonFieldId}), nil
+}
+
+func OrderByClauses(order []models.Sort) []string {
+	clauses := make([]string, len(order))
+
+	for i, s := range order {
+		clauses[i] = personColumns[s.Field]
+		if s.Desc {
+			clauses[i] += " DESC"
+		}
+	}
+
+	return clauses
+}
+
+// AfterKeyset selects rows which follow after in the given order:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ..., with < for descending fields.
+func AfterKeyset(order []models.Sort, after *models.Person) squirrel.Sqlizer {
+	or := make(squirrel.Or, len(order))
+
+	for i, s := range order {
+		and := make(squirrel.And, 0, i+1)
+
+		for _, prev := range order[:i] {
+			and = append(and, squirrel.Eq{personColumns[prev.Field]: PersonFieldValue(after, prev.Field)})
+		}
+
+		column, value := personColumns[s.Field], PersonFieldValue(after, s.Field)
+		if s.Desc {
+			and = append(and, squirrel.Lt{column: value})
+		} else {
+			and = append(and, squirrel.Gt{column: value})
+		}
+
+		if len(and) == 1 {
+			or[i] = and[0]
+		} else {
+			or[i] = and
+		}
+	}
+
+	if len(or) == 1 {
+		return or[0]
+	}
+
+	return or
+}
+
+func PersonFieldValue(modelBL *models.Person, field models.PersonField) interface{} {
+	switch field {
+	case models.PersonFieldName:
+		return modelBL.Name
+	case models.PersonFieldAddress:
+		return modelBL.Address
+	case models.PersonFieldWork:
+		return modelBL.Work
+	case models.PersonFieldAge:
+		return modelBL.Age
+	default:
+		return modelBL.Id
+	}
+}
+
+// TsQuery turns free text into a prefix query of all its words,
+// "ivanov mosc" becomes "ivanov:* & mosc:*".
+func TsQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, word := range words {
+		words[i] = strings.ToLower(word) + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// SimilarityExpr is the best trigram similarity of column to any of variants.
+func SimilarityExpr(column string, variants []string) (string, []interface{}) {
+	parts := make([]string, len(variants))
+	args := make([]interface{}, len(variants))
+
+	for i, v := range variants {
+		parts[i] = "similarity(" + column + ", ?)"
+		args[i] = v
+	}
+
+	return "GREATEST(" + strings.Join(parts, ", ") + ")", args
+}
+
+// TrigramMatchExpr matches column to any of variants by the trigram
+// similarity threshold.
+func TrigramMatchExpr(column string, variants []string) (string, []interface{}) {
+	parts := make([]string, len(variants))
+	args := make([]interface{}, len(variants))
+
+	for i, v := range variants {
+		parts[i] = column + " % ?"
+		args[i] = v
+	}
+
+	return "(" + strings.Join(parts, " OR ") + ")", args
+}
+
+// EscapeLike escapes LIKE wildcards so the pattern matches the value literally.
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
 }
 
 func PersonDBToBL(modelDB *PersonDB) (*models.Person, error) {
 	return &models.Person{
-		Id:      modelDB.id,
-		Name:    modelDB.name,
-		Address: modelDB.address,
-		Work:    modelDB.work,
-		Age:     modelDB.age,
+		Id:        modelDB.id,
+		Name:      modelDB.name,
+		Address:   modelDB.address,
+		Work:      modelDB.work,
+		Age:       modelDB.age,
+		Version:   modelDB.version,
+		CreatedAt: modelDB.createdAt,
+		UpdatedAt: modelDB.updatedAt,
+		DeletedAt: modelDB.deletedAt,
 	}, nil
 }
 
 func PersonBLToDB(modelBL *models.Person) (*PersonDB, error) {
 	return &PersonDB{
-		id:      modelBL.Id,
-		name:    modelBL.Name,
-		address: modelBL.Address,
-		work:    modelBL.Work,
-		age:     modelBL.Age,
+		id:        modelBL.Id,
+		name:      modelBL.Name,
+		address:   modelBL.Address,
+		work:      modelBL.Work,
+		age:       modelBL.Age,
+		version:   modelBL.Version,
+		createdAt: modelBL.CreatedAt,
+		updatedAt: modelBL.UpdatedAt,
+		deletedAt: modelBL.DeletedAt,
 	}, nil
 }
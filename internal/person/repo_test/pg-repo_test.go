@@ -7,19 +7,48 @@ import (
 	"bmstu-dips-lab1/pkg/postgres"
 	"context"
 	"errors"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/driftprogramming/pgxpoolmock"
 	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
 	"github.com/pashagolub/pgxmock"
 	"github.com/stretchr/testify/assert"
 )
 
 var (
 	_builder = squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	_work        = "work1"
+	_addressPart = "s_1"
+	_ageMin      = 10
+	_ageMax      = 20
+	_now         = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	_actor       = "user1"
 )
 
+// recordedSql is write of a person with its revision, which takes
+// revisionArgs and keeps after, recorded by the same statement
+func recordedSql(write string, revisionArgs string, after string, columns string) string {
+	return "WITH person_ AS (" + write + " RETURNING id_, name_, address_, work_, age_, version_, created_at_, updated_at_), " +
+		"revision_ AS (INSERT INTO persons_history_ (person_id_, version_, operation_, actor_, after_) SELECT id_, version_, " + revisionArgs + ", " + after + " FROM person_) " +
+		"SELECT " + columns + " FROM person_"
+}
+
+const _snapshot = "jsonb_build_object('name', name_, 'address', address_, 'work', work_, 'age', age_)"
+
+// errRow is a row of QueryRow that fails to scan, like one that was not found
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
 func TestPersonRepo_Create(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
@@ -34,6 +63,8 @@ func TestPersonRepo_Create(t *testing.T) {
 
 	r := repo.NewPersonRepo(&db)
 
+	createSql := recordedSql("INSERT INTO persons_ (name_, address_, work_, age_) VALUES ($1,$2,$3,$4)", "$5, $6", _snapshot, "id_, version_, created_at_, updated_at_")
+
 	type mockBehavior func(ctx context.Context, form *models.Person)
 
 	testTable := []struct {
@@ -53,16 +84,19 @@ func TestPersonRepo_Create(t *testing.T) {
 				Age:     12,
 			},
 			mockBehavior: func(ctx context.Context, person *models.Person) {
-				pgxRows := pgxpoolmock.NewRows([]string{"id_"}).AddRow(345).ToPgxRows()
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "version_", "created_at_", "updated_at_"}).AddRow(345, 1, _now, _now).ToPgxRows()
 				pgxRows.Next()
-				mockPool.EXPECT().QueryRow(ctx, "INSERT INTO persons_ (name_, address_, work_, age_) VALUES ($1,$2,$3,$4) RETURNING \"id_\"", person.Name, person.Address, person.Work, person.Age).Return(pgxRows)
+				mockPool.EXPECT().QueryRow(ctx, createSql, person.Name, person.Address, person.Work, person.Age, "create", _actor).Return(pgxRows)
 			},
 			expectedPerson: models.Person{
-				Id:      345,
-				Name:    "qwerty",
-				Work:    "sdcsd",
-				Address: "ecefvc",
-				Age:     12,
+				Id:        345,
+				Name:      "qwerty",
+				Work:      "sdcsd",
+				Address:   "ecefvc",
+				Age:       12,
+				Version:   1,
+				CreatedAt: _now,
+				UpdatedAt: _now,
 			},
 		},
 		{
@@ -76,7 +110,7 @@ func TestPersonRepo_Create(t *testing.T) {
 			},
 			mockBehavior: func(ctx context.Context, person *models.Person) {
 				pgxRows := pgxpoolmock.NewRows([]string{}).AddRow().ToPgxRows()
-				mockPool.EXPECT().QueryRow(ctx, "INSERT INTO persons_ (name_, address_, work_, age_) VALUES ($1,$2,$3,$4) RETURNING \"id_\"", person.Name, person.Address, person.Work, person.Age).Return(pgxRows)
+				mockPool.EXPECT().QueryRow(ctx, createSql, person.Name, person.Address, person.Work, person.Age, "create", _actor).Return(pgxRows)
 			},
 		},
 	}
@@ -85,7 +119,7 @@ func TestPersonRepo_Create(t *testing.T) {
 		t.Run(testCase.nameTest, func(t *testing.T) {
 			testCase.mockBehavior(testCase.ctx, &testCase.person)
 
-			got, err := r.Create(testCase.ctx, &testCase.person)
+			got, err := r.Create(testCase.ctx, &testCase.person, _actor)
 
 			switch testCase.nameTest {
 			case "ok":
@@ -100,6 +134,182 @@ func TestPersonRepo_Create(t *testing.T) {
 	}
 }
 
+func TestPersonRepo_CreateMany(t *testing.T) {
+	t.Parallel()
+
+	insertSql := recordedSql("INSERT INTO persons_ (name_, address_, work_, age_) VALUES ($1,$2,$3,$4)", "$5, $6", _snapshot, "id_, version_, created_at_, updated_at_")
+
+	persons := []*models.Person{
+		{Name: "qwerty1", Address: "address1", Work: "work1", Age: 11},
+		{Name: "qwerty2", Address: "address2", Work: "work2", Age: 12},
+	}
+
+	type mockBehavior func(mockPool pgxmock.PgxPoolIface)
+
+	testTable := []struct {
+		nameTest        string
+		ctx             context.Context
+		mode            models.BatchMode
+		mockBehavior    mockBehavior
+		expectedResults []*models.PersonResult
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			mode:     models.BatchModeAtomic,
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(insertSql).WithArgs("qwerty1", "address1", "work1", 11, "create", _actor).WillReturnRows(pgxmock.NewRows([]string{"id_", "version_", "created_at_", "updated_at_"}).AddRow(345, 1, _now, _now))
+				mockPool.ExpectQuery(insertSql).WithArgs("qwerty2", "address2", "work2", 12, "create", _actor).WillReturnRows(pgxmock.NewRows([]string{"id_", "version_", "created_at_", "updated_at_"}).AddRow(346, 1, _now, _now))
+				mockPool.ExpectCommit()
+			},
+			expectedResults: []*models.PersonResult{
+				{Person: &models.Person{Id: 345, Name: "qwerty1", Address: "address1", Work: "work1", Age: 11, Version: 1, CreatedAt: _now, UpdatedAt: _now}},
+				{Person: &models.Person{Id: 346, Name: "qwerty2", Address: "address2", Work: "work2", Age: 12, Version: 1, CreatedAt: _now, UpdatedAt: _now}},
+			},
+		},
+		{
+			nameTest: "atomic_error",
+			ctx:      context.Background(),
+			mode:     models.BatchModeAtomic,
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(insertSql).WithArgs("qwerty1", "address1", "work1", 11, "create", _actor).WillReturnRows(pgxmock.NewRows([]string{"id_", "version_", "created_at_", "updated_at_"}).AddRow(345, 1, _now, _now))
+				mockPool.ExpectQuery(insertSql).WithArgs("qwerty2", "address2", "work2", 12, "create", _actor).WillReturnError(errors.New("query_error"))
+				mockPool.ExpectRollback()
+			},
+		},
+		{
+			nameTest: "best_effort",
+			ctx:      context.Background(),
+			mode:     models.BatchModeBestEffort,
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(insertSql).WithArgs("qwerty1", "address1", "work1", 11, "create", _actor).WillReturnError(errors.New("query_error"))
+				mockPool.ExpectRollback()
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(insertSql).WithArgs("qwerty2", "address2", "work2", 12, "create", _actor).WillReturnRows(pgxmock.NewRows([]string{"id_", "version_", "created_at_", "updated_at_"}).AddRow(346, 1, _now, _now))
+				mockPool.ExpectCommit()
+				mockPool.ExpectCommit()
+			},
+			expectedResults: []*models.PersonResult{
+				{Err: errors.New("query_error")},
+				{Person: &models.Person{Id: 346, Name: "qwerty2", Address: "address2", Work: "work2", Age: 12, Version: 1, CreatedAt: _now, UpdatedAt: _now}},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			mockPool, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(pgxmock.QueryMatcherEqual))
+			assert.Equal(t, nil, err)
+
+			r := repo.NewPersonRepo(&postgres.Postgres{
+				Builder: _builder,
+				Pool:    mockPool,
+			})
+
+			testCase.mockBehavior(mockPool)
+
+			got, err := r.CreateMany(testCase.ctx, persons, testCase.mode, _actor)
+
+			switch testCase.nameTest {
+			case "ok", "best_effort":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedResults, got)
+			case "atomic_error":
+				assert.NotEqual(t, nil, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+
+			assert.Equal(t, nil, mockPool.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPersonRepo_Import(t *testing.T) {
+	t.Parallel()
+
+	stagingSql := "CREATE TEMPORARY TABLE persons_import_ (n_ SERIAL, name_ VARCHAR(64), address_ VARCHAR(64), work_ VARCHAR(64), age_ INT) ON COMMIT DROP"
+	moveSql := recordedSql("INSERT INTO persons_ (name_, address_, work_, age_) SELECT name_, address_, work_, age_ FROM persons_import_ ORDER BY n_",
+		"$1, $2", _snapshot, "count(*)")
+
+	type mockBehavior func(mockPool pgxmock.PgxPoolIface)
+
+	testTable := []struct {
+		nameTest         string
+		ctx              context.Context
+		mockBehavior     mockBehavior
+		expectedImported int64
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectExec(stagingSql).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockPool.ExpectCopyFrom(`"persons_import_"`, []string{"name_", "address_", "work_", "age_"}).WillReturnResult(2)
+				mockPool.ExpectQuery(moveSql).WithArgs("create", _actor).WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(2)))
+				mockPool.ExpectCommit()
+			},
+			expectedImported: 2,
+		},
+		{
+			nameTest: "copy_error",
+			ctx:      context.Background(),
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectExec(stagingSql).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockPool.ExpectCopyFrom(`"persons_import_"`, []string{"name_", "address_", "work_", "age_"}).WillReturnError(errors.New("copy_error"))
+				mockPool.ExpectRollback()
+			},
+		},
+		{
+			nameTest: "move_error",
+			ctx:      context.Background(),
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectExec(stagingSql).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockPool.ExpectCopyFrom(`"persons_import_"`, []string{"name_", "address_", "work_", "age_"}).WillReturnResult(2)
+				mockPool.ExpectQuery(moveSql).WithArgs("create", _actor).WillReturnError(errors.New("move_error"))
+				mockPool.ExpectRollback()
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			mockPool, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(pgxmock.QueryMatcherEqual))
+			assert.Equal(t, nil, err)
+
+			r := repo.NewPersonRepo(&postgres.Postgres{
+				Builder: _builder,
+				Pool:    mockPool,
+			})
+
+			testCase.mockBehavior(mockPool)
+
+			got, err := r.Import(testCase.ctx, func() (*models.Person, error) {
+				return nil, io.EOF
+			}, _actor)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedImported, got)
+			case "copy_error", "move_error":
+				assert.NotEqual(t, nil, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+
+			assert.Equal(t, nil, mockPool.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestPersonRepo_GetById(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
@@ -129,16 +339,19 @@ func TestPersonRepo_GetById(t *testing.T) {
 			ctx:      context.Background(),
 			id:       345,
 			mockBehavior: func(ctx context.Context, id int) {
-				pgxRows := pgxpoolmock.NewRows([]string{"name_", "address_", "work_", "age_"}).AddRow("qwerty", "ecefvc", "sdcsd", 12).ToPgxRows()
+				pgxRows := pgxpoolmock.NewRows([]string{"name_", "address_", "work_", "age_", "version_", "created_at_", "updated_at_"}).AddRow("qwerty", "ecefvc", "sdcsd", 12, 1, _now, _now).ToPgxRows()
 				pgxRows.Next()
-				mockPool.EXPECT().QueryRow(ctx, "SELECT name_, address_, work_, age_ FROM persons_ WHERE id_ = $1", id).Return(pgxRows)
+				mockPool.EXPECT().QueryRow(ctx, "SELECT name_, address_, work_, age_, version_, created_at_, updated_at_ FROM persons_ WHERE id_ = $1 AND deleted_at_ IS NULL", id).Return(pgxRows)
 			},
 			expectedPerson: models.Person{
-				Id:      345,
-				Name:    "qwerty",
-				Work:    "sdcsd",
-				Address: "ecefvc",
-				Age:     12,
+				Id:        345,
+				Name:      "qwerty",
+				Work:      "sdcsd",
+				Address:   "ecefvc",
+				Age:       12,
+				Version:   1,
+				CreatedAt: _now,
+				UpdatedAt: _now,
 			},
 		},
 		{
@@ -147,7 +360,7 @@ func TestPersonRepo_GetById(t *testing.T) {
 			id:       345,
 			mockBehavior: func(ctx context.Context, id int) {
 				pgxRows := pgxpoolmock.NewRows([]string{}).AddRow().ToPgxRows()
-				mockPool.EXPECT().QueryRow(ctx, "SELECT name_, address_, work_, age_ FROM persons_ WHERE id_ = $1", id).Return(pgxRows)
+				mockPool.EXPECT().QueryRow(ctx, "SELECT name_, address_, work_, age_, version_, created_at_, updated_at_ FROM persons_ WHERE id_ = $1 AND deleted_at_ IS NULL", id).Return(pgxRows)
 			},
 		},
 	}
@@ -173,7 +386,7 @@ func TestPersonRepo_GetById(t *testing.T) {
 	}
 }
 
-func TestPersonRepo_GetAll(t *testing.T) {
+func TestPersonRepo_GetAsOf(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -187,72 +400,60 @@ func TestPersonRepo_GetAll(t *testing.T) {
 
 	r := repo.NewPersonRepo(&db)
 
-	type mockBehavior func(ctx context.Context)
+	asOfSql := "SELECT version_, changed_at_, after_ FROM persons_history_ WHERE person_id_ = $1 AND changed_at_ <= $2 ORDER BY id_ DESC LIMIT 1"
+
+	type mockBehavior func(ctx context.Context, id int)
 
 	testTable := []struct {
-		nameTest        string
-		ctx             context.Context
-		mockBehavior    mockBehavior
-		expectedPersons []*models.Person
+		nameTest       string
+		ctx            context.Context
+		id             int
+		mockBehavior   mockBehavior
+		expectedPerson *models.Person
 	}{
 		{
 			nameTest: "ok",
 			ctx:      context.Background(),
-			mockBehavior: func(ctx context.Context) {
-				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_"}).AddRow(345, "qwerty1", "address1", "work1", 11).AddRow(346, "qwerty2", "address2", "work2", 12).ToPgxRows()
-				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_ FROM persons_").Return(pgxRows, nil)
-			},
-			expectedPersons: []*models.Person{
-				{
-					Id:      345,
-					Address: "address1",
-					Work:    "work1",
-					Name:    "qwerty1",
-					Age:     11,
-				},
-				{
-					Id:      346,
-					Address: "address2",
-					Work:    "work2",
-					Name:    "qwerty2",
-					Age:     12,
-				},
+			id:       345,
+			mockBehavior: func(ctx context.Context, id int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"version_", "changed_at_", "after_"}).AddRow(2, _now, []byte(`{"name":"qwerty","address":"address","work":"work","age":12}`)).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, asOfSql, id, _now).Return(pgxRows)
 			},
+			expectedPerson: &models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work", Age: 12, Version: 2, UpdatedAt: _now},
 		},
 		{
-			nameTest: "query_error",
+			nameTest: "deleted",
 			ctx:      context.Background(),
-			mockBehavior: func(ctx context.Context) {
-				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_ FROM persons_").Return(nil, errors.New("query_error"))
+			id:       346,
+			mockBehavior: func(ctx context.Context, id int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"version_", "changed_at_", "after_"}).AddRow(0, _now, []byte(nil)).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, asOfSql, id, _now).Return(pgxRows)
 			},
 		},
 		{
-			nameTest: "no_rows",
+			nameTest: "not_created",
 			ctx:      context.Background(),
-			mockBehavior: func(ctx context.Context) {
-				pgxRows := pgxpoolmock.NewRows([]string{}).AddRow().ToPgxRows()
-				pgxRows.Next()
-				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_ FROM persons_").Return(pgxRows, nil)
+			id:       347,
+			mockBehavior: func(ctx context.Context, id int) {
+				mockPool.EXPECT().QueryRow(ctx, asOfSql, id, _now).Return(errRow{pgx.ErrNoRows})
 			},
-			expectedPersons: []*models.Person{},
 		},
 	}
 
 	for _, testCase := range testTable {
 		t.Run(testCase.nameTest, func(t *testing.T) {
-			testCase.mockBehavior(testCase.ctx)
+			testCase.mockBehavior(testCase.ctx, testCase.id)
 
-			got, err := r.GetAll(testCase.ctx)
+			got, err := r.GetAsOf(testCase.ctx, testCase.id, _now)
 
 			switch testCase.nameTest {
 			case "ok":
 				assert.Equal(t, nil, err)
-				assert.Equal(t, testCase.expectedPersons, got)
-			case "query_error":
-				assert.NotEqual(t, nil, err)
-			case "no_rows":
-				assert.Equal(t, nil, err)
-				assert.Equal(t, []*models.Person{}, got)
+				assert.Equal(t, testCase.expectedPerson, got)
+			case "deleted", "not_created":
+				assert.Equal(t, errs.ErrNotFound, err)
 			default:
 				assert.Error(t, errors.New("No case"), "No case")
 			}
@@ -260,7 +461,7 @@ func TestPersonRepo_GetAll(t *testing.T) {
 	}
 }
 
-func TestFormRepo_Update(t *testing.T) {
+func TestPersonRepo_GetAll(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -274,103 +475,209 @@ func TestFormRepo_Update(t *testing.T) {
 
 	r := repo.NewPersonRepo(&db)
 
-	type mockBehavior func(ctx context.Context, person *models.Person, toUpdate *models.Person)
+	type mockBehavior func(ctx context.Context)
 
 	testTable := []struct {
-		nameTest       string
-		ctx            context.Context
-		person         models.Person
-		toUpdate       models.Person
-		mockBehavior   mockBehavior
-		expectedPerson models.Person
+		nameTest     string
+		ctx          context.Context
+		filter       models.PersonFilter
+		page         models.Page
+		mockBehavior mockBehavior
+		expectedPage models.PersonPage
 	}{
 		{
 			nameTest: "ok",
 			ctx:      context.Background(),
-			person: models.Person{
-				Id:      345,
-				Name:    "qwerty",
-				Work:    "work",
-				Address: "address",
-				Age:     12,
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "version_", "updated_at_"}).AddRow(345, "qwerty1", "address1", "work1", 11, 1, _now).AddRow(346, "qwerty2", "address2", "work2", 12, 1, _now).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM persons_ WHERE deleted_at_ IS NULL ORDER BY id_").Return(pgxRows, nil)
+			},
+			expectedPage: models.PersonPage{
+				Persons: []*models.Person{
+					{
+						Id:        345,
+						Address:   "address1",
+						Work:      "work1",
+						Name:      "qwerty1",
+						Age:       11,
+						Version:   1,
+						UpdatedAt: _now,
+					},
+					{
+						Id:        346,
+						Address:   "address2",
+						Work:      "work2",
+						Name:      "qwerty2",
+						Age:       12,
+						Version:   1,
+						UpdatedAt: _now,
+					},
+				},
 			},
-			toUpdate: models.Person{
-				Id:      0,
-				Name:    "",
-				Work:    "newwork",
-				Address: "",
-				Age:     0,
+		},
+		{
+			nameTest: "ok_page",
+			ctx:      context.Background(),
+			page: models.Page{
+				Limit: 1,
+				After: &models.Person{Id: 344},
 			},
-			mockBehavior: func(ctx context.Context, person *models.Person, toUpdate *models.Person) {
-				mockPool.EXPECT().Exec(ctx, "UPDATE persons_ SET work_ = $1 WHERE id_ = $2", person.Work, person.Id).Return(pgxmock.NewResult("UPDATE", 1), nil)
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "version_", "updated_at_"}).AddRow(345, "qwerty1", "address1", "work1", 11, 1, _now).AddRow(346, "qwerty2", "address2", "work2", 12, 1, _now).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM persons_ WHERE deleted_at_ IS NULL AND id_ > $1 ORDER BY id_ LIMIT 2", 344).Return(pgxRows, nil)
 			},
-			expectedPerson: models.Person{
-				Id:      345,
-				Name:    "qwerty",
-				Work:    "work",
-				Address: "address",
-				Age:     12,
+			expectedPage: models.PersonPage{
+				Persons: []*models.Person{
+					{
+						Id:        345,
+						Address:   "address1",
+						Work:      "work1",
+						Name:      "qwerty1",
+						Age:       11,
+						Version:   1,
+						UpdatedAt: _now,
+					},
+				},
+				Next: &models.Person{
+					Id:        345,
+					Address:   "address1",
+					Work:      "work1",
+					Name:      "qwerty1",
+					Age:       11,
+					Version:   1,
+					UpdatedAt: _now,
+				},
 			},
 		},
 		{
-			nameTest: "no_person_to_update",
+			nameTest: "ok_filter",
 			ctx:      context.Background(),
-			person: models.Person{
-				Id:      345,
-				Name:    "qwerty",
-				Work:    "work",
-				Address: "address",
-				Age:     12,
+			filter: models.PersonFilter{
+				Work:            &_work,
+				AddressContains: &_addressPart,
+				AgeMin:          &_ageMin,
+				AgeMax:          &_ageMax,
 			},
-			toUpdate: models.Person{
-				Id:      0,
-				Name:    "",
-				Work:    "newwork",
-				Address: "",
-				Age:     0,
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "version_", "updated_at_"}).AddRow(345, "qwerty1", "address_1", "work1", 11, 1, _now).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM persons_ WHERE deleted_at_ IS NULL AND work_ = $1 AND address_ ILIKE $2 AND age_ >= $3 AND age_ <= $4 ORDER BY id_", "work1", "%s\\_1%", 10, 20).Return(pgxRows, nil)
 			},
-			mockBehavior: func(ctx context.Context, person *models.Person, toUpdate *models.Person) {
-				mockPool.EXPECT().Exec(ctx, "UPDATE persons_ SET work_ = $1 WHERE id_ = $2", person.Work, person.Id).Return(pgxmock.NewResult("UPDATE", 0), nil)
+			expectedPage: models.PersonPage{
+				Persons: []*models.Person{
+					{
+						Id:        345,
+						Address:   "address_1",
+						Work:      "work1",
+						Name:      "qwerty1",
+						Age:       11,
+						Version:   1,
+						UpdatedAt: _now,
+					},
+				},
 			},
 		},
 		{
-			nameTest: "exec_error",
+			nameTest: "ok_sort",
 			ctx:      context.Background(),
-			person: models.Person{
-				Id:      345,
-				Name:    "qwerty",
-				Work:    "work",
-				Address: "address",
-				Age:     12,
+			filter: models.PersonFilter{
+				AgeMin: &_ageMin,
+			},
+			page: models.Page{
+				Limit: 1,
+				After: &models.Person{Id: 344, Name: "qwerty0", Age: 11},
+				Sort: []models.Sort{
+					{Field: models.PersonFieldAge},
+					{Field: models.PersonFieldName, Desc: true},
+				},
+			},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "version_", "updated_at_"}).AddRow(345, "qwerty1", "address1", "work1", 11, 1, _now).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM persons_ WHERE deleted_at_ IS NULL AND age_ >= $1 AND (age_ > $2 OR (age_ = $3 AND name_ < $4) OR (age_ = $5 AND name_ = $6 AND id_ > $7)) ORDER BY age_, name_ DESC, id_ LIMIT 2", 10, 11, 11, "qwerty0", 11, "qwerty0", 344).Return(pgxRows, nil)
+			},
+			expectedPage: models.PersonPage{
+				Persons: []*models.Person{
+					{
+						Id:        345,
+						Address:   "address1",
+						Work:      "work1",
+						Name:      "qwerty1",
+						Age:       11,
+						Version:   1,
+						UpdatedAt: _now,
+					},
+				},
 			},
-			toUpdate: models.Person{
-				Id:      0,
-				Name:    "",
-				Work:    "newwork",
-				Address: "",
-				Age:     0,
+		},
+		{
+			nameTest: "ok_as_of",
+			ctx:      context.Background(),
+			filter:   models.PersonFilter{Work: &_work, AsOf: &_now},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "version_", "updated_at_"}).AddRow(345, "qwerty1", "address1", "work1", 11, 1, _now).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM "+
+					"(SELECT person_id_ AS id_, after_->>'name' AS name_, after_->>'address' AS address_, after_->>'work' AS work_, (after_->>'age')::int AS age_, version_, changed_at_ AS updated_at_ FROM "+
+					"(SELECT DISTINCT ON (person_id_) person_id_, version_, changed_at_, after_ FROM persons_history_ WHERE changed_at_ <= $1 ORDER BY person_id_, id_ DESC) AS revisions_ "+
+					"WHERE after_ IS NOT NULL) AS persons_ WHERE work_ = $2 ORDER BY id_", _now, "work1").Return(pgxRows, nil)
+			},
+			expectedPage: models.PersonPage{
+				Persons: []*models.Person{
+					{
+						Id:        345,
+						Address:   "address1",
+						Work:      "work1",
+						Name:      "qwerty1",
+						Age:       11,
+						Version:   1,
+						UpdatedAt: _now,
+					},
+				},
+			},
+		},
+		{
+			nameTest: "invalid_sort",
+			ctx:      context.Background(),
+			page: models.Page{
+				Sort: []models.Sort{
+					{Field: models.PersonFieldAge},
+					{Field: models.PersonFieldAge, Desc: true},
+				},
+			},
+			mockBehavior: func(ctx context.Context) {},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			mockBehavior: func(ctx context.Context) {
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM persons_ WHERE deleted_at_ IS NULL ORDER BY id_").Return(nil, errors.New("query_error"))
+			},
+		},
+		{
+			nameTest: "no_rows",
+			ctx:      context.Background(),
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{}).AddRow().ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_ FROM persons_ WHERE deleted_at_ IS NULL ORDER BY id_").Return(pgxRows, nil)
 			},
-			mockBehavior: func(ctx context.Context, person *models.Person, toUpdate *models.Person) {
-				mockPool.EXPECT().Exec(ctx, "UPDATE persons_ SET work_ = $1 WHERE id_ = $2", person.Work, person.Id).Return(nil, errors.New("exec_error"))
+			expectedPage: models.PersonPage{
+				Persons: []*models.Person{},
 			},
 		},
 	}
 
 	for _, testCase := range testTable {
 		t.Run(testCase.nameTest, func(t *testing.T) {
-			testCase.mockBehavior(testCase.ctx, &testCase.person, &testCase.toUpdate)
+			testCase.mockBehavior(testCase.ctx)
 
-			got, err := r.Update(testCase.ctx, &testCase.person, &testCase.toUpdate)
+			got, err := r.GetAll(testCase.ctx, &testCase.filter, &testCase.page)
 
 			switch testCase.nameTest {
-			case "ok":
+			case "ok", "ok_page", "ok_filter", "ok_sort", "ok_as_of", "no_rows":
 				assert.Equal(t, nil, err)
-				assert.Equal(t, testCase.expectedPerson, *got)
-			case "invalid_inputs_id":
+				assert.Equal(t, testCase.expectedPage, *got)
+			case "invalid_sort":
 				assert.Equal(t, errs.ErrInvalidContent, err)
-			case "no_person_to_update":
-				assert.Equal(t, errs.ErrNoContent, err)
-			case "exec_error":
+			case "query_error":
 				assert.NotEqual(t, nil, err)
 			default:
 				assert.Error(t, errors.New("No case"), "No case")
@@ -379,7 +686,7 @@ func TestFormRepo_Update(t *testing.T) {
 	}
 }
 
-func TestFormRepo_Delete(t *testing.T) {
+func TestPersonRepo_Stat(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -393,54 +700,1274 @@ func TestFormRepo_Delete(t *testing.T) {
 
 	r := repo.NewPersonRepo(&db)
 
-	type mockBehavior func(ctx context.Context, id int)
+	type mockBehavior func(ctx context.Context)
 
 	testTable := []struct {
 		nameTest     string
 		ctx          context.Context
-		id           int
+		filter       models.PersonFilter
 		mockBehavior mockBehavior
+		expectedStat models.PersonStat
 	}{
 		{
 			nameTest: "ok",
 			ctx:      context.Background(),
-			id:       345,
-			mockBehavior: func(ctx context.Context, id int) {
-				mockPool.EXPECT().Exec(ctx, "DELETE FROM persons_ WHERE id_ = $1", id).Return(pgxmock.NewResult("DELETE", 1), nil)
+			filter:   models.PersonFilter{Work: &_work},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"count", "max"}).AddRow(int64(2), &_now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, "SELECT count(*), max(updated_at_) FROM persons_ WHERE deleted_at_ IS NULL AND work_ = $1", "work1").Return(pgxRows)
 			},
+			expectedStat: models.PersonStat{Count: 2, LastModified: _now},
 		},
 		{
-			nameTest: "no_person_to_delete",
+			nameTest: "empty",
 			ctx:      context.Background(),
-			id:       345,
-			mockBehavior: func(ctx context.Context, id int) {
-				mockPool.EXPECT().Exec(ctx, "DELETE FROM persons_ WHERE id_ = $1", id).Return(pgxmock.NewResult("DELETE", 0), nil)
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"count", "max"}).AddRow(int64(0), (*time.Time)(nil)).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, "SELECT count(*), max(updated_at_) FROM persons_ WHERE deleted_at_ IS NULL").Return(pgxRows)
 			},
+			expectedStat: models.PersonStat{},
 		},
 		{
-			nameTest: "exec_error",
+			nameTest: "query_error",
 			ctx:      context.Background(),
-			id:       345,
-			mockBehavior: func(ctx context.Context, id int) {
-				mockPool.EXPECT().Exec(ctx, "DELETE FROM persons_ WHERE id_ = $1", id).Return(nil, errors.New("exec_error"))
+			mockBehavior: func(ctx context.Context) {
+				mockPool.EXPECT().QueryRow(ctx, "SELECT count(*), max(updated_at_) FROM persons_ WHERE deleted_at_ IS NULL").Return(errRow{errors.New("query_error")})
 			},
 		},
 	}
 
 	for _, testCase := range testTable {
 		t.Run(testCase.nameTest, func(t *testing.T) {
-			testCase.mockBehavior(testCase.ctx, testCase.id)
+			testCase.mockBehavior(testCase.ctx)
 
-			err := r.Delete(testCase.ctx, testCase.id)
+			got, err := r.Stat(testCase.ctx, &testCase.filter)
 
 			switch testCase.nameTest {
-			case "ok":
+			case "ok", "empty":
 				assert.Equal(t, nil, err)
-			case "invalid_inputs":
-				assert.Equal(t, errs.ErrInvalidContent, err)
-			case "no_person_to_delete":
-				assert.Equal(t, errs.ErrNoContent, err)
-			case "exec_error":
+				assert.Equal(t, testCase.expectedStat, *got)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_ForEach(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	type mockBehavior func(ctx context.Context)
+
+	testTable := []struct {
+		nameTest        string
+		ctx             context.Context
+		filter          models.PersonFilter
+		mockBehavior    mockBehavior
+		fnErr           error
+		expectedPersons []*models.Person
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			filter:   models.PersonFilter{Work: &_work},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_"}).AddRow(345, "qwerty1", "address1", "work1", 11).AddRow(346, "qwerty2", "address2", "work1", 12).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_ FROM persons_ WHERE deleted_at_ IS NULL AND work_ = $1 ORDER BY id_", "work1").Return(pgxRows, nil)
+			},
+			expectedPersons: []*models.Person{
+				{Id: 345, Name: "qwerty1", Address: "address1", Work: "work1", Age: 11},
+				{Id: 346, Name: "qwerty2", Address: "address2", Work: "work1", Age: 12},
+			},
+		},
+		{
+			nameTest: "fn_error",
+			ctx:      context.Background(),
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_"}).AddRow(345, "qwerty1", "address1", "work1", 11).AddRow(346, "qwerty2", "address2", "work1", 12).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_ FROM persons_ WHERE deleted_at_ IS NULL ORDER BY id_").Return(pgxRows, nil)
+			},
+			fnErr: errors.New("fn_error"),
+			expectedPersons: []*models.Person{
+				{Id: 345, Name: "qwerty1", Address: "address1", Work: "work1", Age: 11},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx)
+
+			got := make([]*models.Person, 0)
+
+			err := r.ForEach(testCase.ctx, &testCase.filter, nil, func(person *models.Person) error {
+				got = append(got, person)
+				return testCase.fnErr
+			})
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPersons, got)
+			case "fn_error":
+				assert.Equal(t, testCase.fnErr, err)
+				assert.Equal(t, testCase.expectedPersons, got)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Search(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	type mockBehavior func(ctx context.Context)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		search       models.PersonSearch
+		page         models.SearchPage
+		mockBehavior mockBehavior
+		expectedPage models.PersonHitPage
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			search:   models.PersonSearch{Query: "Ivanov, mosc"},
+			page: models.SearchPage{
+				Limit: 1,
+				After: &models.PersonHit{Person: &models.Person{Id: 344}, Rank: 0.5},
+			},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "rank_"}).AddRow(345, "ivanov", "moscow", "work1", 11, float32(0.5)).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, rank_ FROM (SELECT id_, name_, address_, work_, age_, ts_rank(search_, to_tsquery('simple', $1)) AS rank_ FROM persons_ WHERE deleted_at_ IS NULL AND search_ @@ to_tsquery('simple', $2)) AS hits_ WHERE (rank_ < $3 OR (rank_ = $4 AND id_ > $5)) ORDER BY rank_ DESC, id_ LIMIT 2", "ivanov:* & mosc:*", "ivanov:* & mosc:*", float32(0.5), float32(0.5), 344).Return(pgxRows, nil)
+			},
+			expectedPage: models.PersonHitPage{
+				Hits: []*models.PersonHit{
+					{
+						Person: &models.Person{
+							Id:      345,
+							Name:    "ivanov",
+							Address: "moscow",
+							Work:    "work1",
+							Age:     11,
+						},
+						Rank: 0.5,
+					},
+				},
+			},
+		},
+		{
+			nameTest:     "invalid_inputs",
+			ctx:          context.Background(),
+			search:       models.PersonSearch{Query: " ,- "},
+			mockBehavior: func(ctx context.Context) {},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx)
+
+			got, err := r.Search(testCase.ctx, &testCase.search, &testCase.page)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPage, *got)
+			case "invalid_inputs":
+				assert.Equal(t, errs.ErrInvalidContent, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_SearchFuzzy(t *testing.T) {
+	t.Parallel()
+
+	hitsSql := "SELECT id_, name_, address_, work_, age_, rank_ FROM (SELECT id_, name_, address_, work_, age_, GREATEST(similarity(name_, $1), similarity(name_, $2)) AS rank_ " +
+		"FROM persons_ WHERE deleted_at_ IS NULL AND (name_ % $3 OR name_ % $4)) AS hits_ ORDER BY rank_ DESC, id_ LIMIT 21"
+	thresholdSql := "SELECT set_config('pg_trgm.similarity_threshold', $1, true)"
+
+	type mockBehavior func(mockPool pgxmock.PgxPoolIface)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		search       models.PersonSearch
+		mockBehavior mockBehavior
+		expectedPage models.PersonHitPage
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			search:   models.PersonSearch{Query: "Efremov", Mode: models.SearchModeFuzzy, Threshold: 0.3},
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectExec(thresholdSql).WithArgs("0.3").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+				mockPool.ExpectQuery(hitsSql).WithArgs("efremov", "ефремов", "efremov", "ефремов").
+					WillReturnRows(pgxmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "rank_"}).AddRow(345, "Ефремов", "address1", "work1", 11, float32(1)))
+				mockPool.ExpectCommit()
+			},
+			expectedPage: models.PersonHitPage{
+				Hits: []*models.PersonHit{
+					{
+						Person: &models.Person{
+							Id:      345,
+							Name:    "Ефремов",
+							Address: "address1",
+							Work:    "work1",
+							Age:     11,
+						},
+						Rank: 1,
+					},
+				},
+			},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			search:   models.PersonSearch{Query: "Efremov", Mode: models.SearchModeFuzzy, Threshold: 0.5},
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectExec(thresholdSql).WithArgs("0.5").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+				mockPool.ExpectQuery(hitsSql).WithArgs("efremov", "ефремов", "efremov", "ефремов").WillReturnError(errors.New("query_error"))
+				mockPool.ExpectRollback()
+			},
+		},
+		{
+			nameTest:     "invalid_threshold",
+			ctx:          context.Background(),
+			search:       models.PersonSearch{Query: "Efremov", Mode: models.SearchModeFuzzy, Threshold: 1.5},
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			mockPool, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(pgxmock.QueryMatcherEqual))
+			assert.Equal(t, nil, err)
+
+			r := repo.NewPersonRepo(&postgres.Postgres{
+				Builder: _builder,
+				Pool:    mockPool,
+			})
+
+			testCase.mockBehavior(mockPool)
+
+			got, err := r.Search(testCase.ctx, &testCase.search, &models.SearchPage{Limit: 20})
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPage, *got)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			case "invalid_threshold":
+				assert.Equal(t, errs.ErrInvalidContent, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+
+			assert.Equal(t, nil, mockPool.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFormRepo_Update(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	updateSql := recordedSql("UPDATE persons_ SET work_ = $1, version_ = version_ + 1, updated_at_ = now() WHERE deleted_at_ IS NULL AND id_ = $2", "$3, $4", _snapshot,
+		"name_, address_, work_, age_, version_, created_at_, updated_at_")
+
+	type mockBehavior func(ctx context.Context, person *models.Person, mask models.PersonMask)
+
+	testTable := []struct {
+		nameTest       string
+		ctx            context.Context
+		person         models.Person
+		mask           models.PersonMask
+		mockBehavior   mockBehavior
+		expectedPerson models.Person
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			person: models.Person{
+				Id:   345,
+				Work: "work",
+			},
+			mask: models.NewPersonMask(models.PersonFieldWork),
+			mockBehavior: func(ctx context.Context, person *models.Person, mask models.PersonMask) {
+				pgxRows := pgxpoolmock.NewRows([]string{"name_", "address_", "work_", "age_", "version_", "created_at_", "updated_at_"}).AddRow("qwerty", "address", "work", 12, 2, _now, _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, updateSql, person.Work, person.Id, "update", _actor).Return(pgxRows)
+			},
+			expectedPerson: models.Person{
+				Id:        345,
+				Name:      "qwerty",
+				Work:      "work",
+				Address:   "address",
+				Age:       12,
+				Version:   2,
+				CreatedAt: _now,
+				UpdatedAt: _now,
+			},
+		},
+		{
+			nameTest: "no_person_to_update",
+			ctx:      context.Background(),
+			person: models.Person{
+				Id:   345,
+				Work: "work",
+			},
+			mask: models.NewPersonMask(models.PersonFieldWork),
+			mockBehavior: func(ctx context.Context, person *models.Person, mask models.PersonMask) {
+				mockPool.EXPECT().QueryRow(ctx, updateSql, person.Work, person.Id, "update", _actor).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			person: models.Person{
+				Id:   345,
+				Work: "work",
+			},
+			mask: models.NewPersonMask(models.PersonFieldWork),
+			mockBehavior: func(ctx context.Context, person *models.Person, mask models.PersonMask) {
+				mockPool.EXPECT().QueryRow(ctx, updateSql, person.Work, person.Id, "update", _actor).Return(errRow{errors.New("query_error")})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, &testCase.person, testCase.mask)
+
+			got, err := r.Update(testCase.ctx, &testCase.person, testCase.mask, _actor)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPerson, *got)
+			case "no_person_to_update":
+				assert.Equal(t, errs.ErrNoContent, err)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+				assert.NotEqual(t, errs.ErrNoContent, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Replace(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	type mockBehavior func(ctx context.Context, person *models.Person)
+
+	replaceSql := recordedSql("UPDATE persons_ SET name_ = $1, address_ = $2, work_ = $3, age_ = $4, version_ = version_ + 1, updated_at_ = now() WHERE deleted_at_ IS NULL AND id_ = $5",
+		"$6, $7", _snapshot, "version_, created_at_, updated_at_")
+	replaceVersionSql := recordedSql("UPDATE persons_ SET name_ = $1, address_ = $2, work_ = $3, age_ = $4, version_ = version_ + 1, updated_at_ = now() WHERE deleted_at_ IS NULL AND id_ = $5 AND version_ = $6",
+		"$7, $8", _snapshot, "version_, created_at_, updated_at_")
+	existsSql := "SELECT 1 FROM persons_ WHERE deleted_at_ IS NULL AND id_ = $1"
+
+	testTable := []struct {
+		nameTest       string
+		ctx            context.Context
+		person         models.Person
+		mockBehavior   mockBehavior
+		expectedPerson models.Person
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			person: models.Person{
+				Id:      345,
+				Name:    "qwerty",
+				Work:    "work",
+				Address: "address",
+				Age:     12,
+			},
+			mockBehavior: func(ctx context.Context, person *models.Person) {
+				pgxRows := pgxpoolmock.NewRows([]string{"version_", "created_at_", "updated_at_"}).AddRow(2, _now, _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, replaceSql, person.Name, person.Address, person.Work, person.Age, person.Id, "update", _actor).Return(pgxRows)
+			},
+			expectedPerson: models.Person{
+				Id:        345,
+				Name:      "qwerty",
+				Work:      "work",
+				Address:   "address",
+				Age:       12,
+				Version:   2,
+				CreatedAt: _now,
+				UpdatedAt: _now,
+			},
+		},
+		{
+			nameTest: "not_found",
+			ctx:      context.Background(),
+			person: models.Person{
+				Id:      346,
+				Name:    "qwerty",
+				Work:    "work",
+				Address: "address",
+				Age:     12,
+			},
+			mockBehavior: func(ctx context.Context, person *models.Person) {
+				mockPool.EXPECT().QueryRow(ctx, replaceSql, person.Name, person.Address, person.Work, person.Age, person.Id, "update", _actor).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+		{
+			nameTest: "stale_version",
+			ctx:      context.Background(),
+			person: models.Person{
+				Id:      347,
+				Name:    "qwerty",
+				Work:    "work",
+				Address: "address",
+				Age:     12,
+				Version: 3,
+			},
+			mockBehavior: func(ctx context.Context, person *models.Person) {
+				mockPool.EXPECT().QueryRow(ctx, replaceVersionSql, person.Name, person.Address, person.Work, person.Age, person.Id, person.Version, "update", _actor).Return(errRow{pgx.ErrNoRows})
+
+				foundRows := pgxpoolmock.NewRows([]string{"?column?"}).AddRow(1).ToPgxRows()
+				foundRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, existsSql, person.Id).Return(foundRows)
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, &testCase.person)
+
+			got, err := r.Replace(testCase.ctx, &testCase.person, _actor)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPerson, *got)
+			case "not_found":
+				assert.Equal(t, errs.ErrNotFound, err)
+			case "stale_version":
+				assert.Equal(t, errs.ErrPreconditionFailed, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Revert(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	revertSql := recordedSql("UPDATE persons_ SET name_ = $1, address_ = $2, work_ = $3, age_ = $4, deleted_at_ = $5, version_ = version_ + 1, updated_at_ = now() WHERE id_ = $6",
+		"$7, $8", _snapshot, "version_, created_at_, updated_at_")
+	revertVersionSql := recordedSql("UPDATE persons_ SET name_ = $1, address_ = $2, work_ = $3, age_ = $4, deleted_at_ = $5, version_ = version_ + 1, updated_at_ = now() WHERE id_ = $6 AND version_ = $7",
+		"$8, $9", _snapshot, "version_, created_at_, updated_at_")
+	// a person in the trash is reverted as well
+	existsSql := "SELECT 1 FROM persons_ WHERE id_ = $1"
+
+	type mockBehavior func(ctx context.Context, person models.Person)
+
+	testTable := []struct {
+		nameTest       string
+		ctx            context.Context
+		person         models.Person
+		mockBehavior   mockBehavior
+		expectedPerson *models.Person
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			person:   models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work", Age: 12},
+			mockBehavior: func(ctx context.Context, person models.Person) {
+				pgxRows := pgxpoolmock.NewRows([]string{"version_", "created_at_", "updated_at_"}).AddRow(5, _now, _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, revertSql, person.Name, person.Address, person.Work, person.Age, nil, person.Id, "revert", _actor).Return(pgxRows)
+			},
+			expectedPerson: &models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work", Age: 12, Version: 5, CreatedAt: _now, UpdatedAt: _now},
+		},
+		{
+			nameTest: "purged",
+			ctx:      context.Background(),
+			person:   models.Person{Id: 346, Name: "qwerty", Address: "address", Work: "work", Age: 12},
+			mockBehavior: func(ctx context.Context, person models.Person) {
+				mockPool.EXPECT().QueryRow(ctx, revertSql, person.Name, person.Address, person.Work, person.Age, nil, person.Id, "revert", _actor).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+		{
+			nameTest: "stale_version",
+			ctx:      context.Background(),
+			person:   models.Person{Id: 347, Name: "qwerty", Address: "address", Work: "work", Age: 12, Version: 3},
+			mockBehavior: func(ctx context.Context, person models.Person) {
+				mockPool.EXPECT().QueryRow(ctx, revertVersionSql, person.Name, person.Address, person.Work, person.Age, nil, person.Id, person.Version, "revert", _actor).Return(errRow{pgx.ErrNoRows})
+
+				foundRows := pgxpoolmock.NewRows([]string{"?column?"}).AddRow(1).ToPgxRows()
+				foundRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, existsSql, person.Id).Return(foundRows)
+			},
+		},
+		{
+			nameTest: "purged_version",
+			ctx:      context.Background(),
+			person:   models.Person{Id: 348, Name: "qwerty", Address: "address", Work: "work", Age: 12, Version: 3},
+			mockBehavior: func(ctx context.Context, person models.Person) {
+				mockPool.EXPECT().QueryRow(ctx, revertVersionSql, person.Name, person.Address, person.Work, person.Age, nil, person.Id, person.Version, "revert", _actor).Return(errRow{pgx.ErrNoRows})
+				mockPool.EXPECT().QueryRow(ctx, existsSql, person.Id).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.person)
+
+			got, err := r.Revert(testCase.ctx, &testCase.person, _actor)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPerson, got)
+			case "purged", "purged_version":
+				assert.Equal(t, errs.ErrNotFound, err)
+			case "stale_version":
+				assert.Equal(t, errs.ErrPreconditionFailed, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_GetRevision(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	revisionSql := "SELECT version_, operation_, actor_, changed_at_, after_ FROM persons_history_ WHERE id_ = $1 AND person_id_ = $2"
+
+	type mockBehavior func(ctx context.Context, id int, rev int64)
+
+	testTable := []struct {
+		nameTest         string
+		ctx              context.Context
+		id               int
+		rev              int64
+		mockBehavior     mockBehavior
+		expectedRevision *models.PersonRevision
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			id:       345,
+			rev:      8,
+			mockBehavior: func(ctx context.Context, id int, rev int64) {
+				pgxRows := pgxpoolmock.NewRows([]string{"version_", "operation_", "actor_", "changed_at_", "after_"}).
+					AddRow(2, "update", "user1", _now, []byte(`{"name":"qwerty","address":"address","work":"work","age":12}`)).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, revisionSql, rev, id).Return(pgxRows)
+			},
+			expectedRevision: &models.PersonRevision{Revision: 8, PersonId: 345, Version: 2, Operation: models.PersonOperationUpdate, Actor: "user1", ChangedAt: _now,
+				After: &models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work", Age: 12}},
+		},
+		{
+			nameTest: "not_found",
+			ctx:      context.Background(),
+			id:       345,
+			rev:      9,
+			mockBehavior: func(ctx context.Context, id int, rev int64) {
+				mockPool.EXPECT().QueryRow(ctx, revisionSql, rev, id).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.id, testCase.rev)
+
+			got, err := r.GetRevision(testCase.ctx, testCase.id, testCase.rev)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedRevision, got)
+			case "not_found":
+				assert.Equal(t, errs.ErrNotFound, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Upsert(t *testing.T) {
+	t.Parallel()
+
+	type mockBehavior func(mockPool pgxmock.PgxPoolIface)
+
+	upsertSqlOn := func(conflict string) string {
+		return "WITH person_ AS (INSERT INTO persons_ (id_, name_, address_, work_, age_) VALUES ($1,$2,$3,$4,$5) " +
+			conflict + "RETURNING (xmax = 0) AS created_, id_, name_, address_, work_, age_, version_, created_at_, updated_at_), " +
+			"revision_ AS (INSERT INTO persons_history_ (person_id_, version_, operation_, actor_, after_) " +
+			"SELECT id_, version_, CASE WHEN created_ THEN $6 ELSE $7 END, $8, " + _snapshot + " FROM person_) " +
+			"SELECT created_, version_, created_at_, updated_at_ FROM person_"
+	}
+	upsertSql := upsertSqlOn("ON CONFLICT (id_) DO UPDATE SET name_ = EXCLUDED.name_, address_ = EXCLUDED.address_, work_ = EXCLUDED.work_, age_ = EXCLUDED.age_, " +
+		"version_ = persons_.version_ + 1, updated_at_ = now() WHERE persons_.deleted_at_ IS NULL ")
+	createSql := upsertSqlOn("ON CONFLICT (id_) DO NOTHING ")
+	setvalSql := "SELECT setval(pg_get_serial_sequence('persons_', 'id_'), GREATEST(nextval(pg_get_serial_sequence('persons_', 'id_')), $1))"
+
+	person := models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work", Age: 12}
+
+	testTable := []struct {
+		nameTest     string
+		createOnly   bool
+		mockBehavior mockBehavior
+	}{
+		{
+			nameTest: "created",
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(upsertSql).WithArgs(345, "qwerty", "address", "work", 12, "create", "update", _actor).WillReturnRows(pgxmock.NewRows([]string{"created_", "version_", "created_at_", "updated_at_"}).AddRow(true, 1, _now, _now))
+				mockPool.ExpectExec(setvalSql).WithArgs(345).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+				mockPool.ExpectCommit()
+			},
+		},
+		{
+			nameTest: "replaced",
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(upsertSql).WithArgs(345, "qwerty", "address", "work", 12, "create", "update", _actor).WillReturnRows(pgxmock.NewRows([]string{"created_", "version_", "created_at_", "updated_at_"}).AddRow(false, 2, _now, _now))
+				mockPool.ExpectCommit()
+			},
+		},
+		{
+			nameTest: "query_error",
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(upsertSql).WithArgs(345, "qwerty", "address", "work", 12, "create", "update", _actor).WillReturnError(errors.New("query_error"))
+				mockPool.ExpectRollback()
+			},
+		},
+		{
+			nameTest: "in_trash",
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(upsertSql).WithArgs(345, "qwerty", "address", "work", 12, "create", "update", _actor).WillReturnError(pgx.ErrNoRows)
+				mockPool.ExpectRollback()
+			},
+		},
+		{
+			nameTest:   "create_only",
+			createOnly: true,
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(createSql).WithArgs(345, "qwerty", "address", "work", 12, "create", "update", _actor).WillReturnRows(pgxmock.NewRows([]string{"created_", "version_", "created_at_", "updated_at_"}).AddRow(true, 1, _now, _now))
+				mockPool.ExpectExec(setvalSql).WithArgs(345).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+				mockPool.ExpectCommit()
+			},
+		},
+		{
+			nameTest:   "create_only_exists",
+			createOnly: true,
+			mockBehavior: func(mockPool pgxmock.PgxPoolIface) {
+				mockPool.ExpectBegin()
+				mockPool.ExpectQuery(createSql).WithArgs(345, "qwerty", "address", "work", 12, "create", "update", _actor).WillReturnError(pgx.ErrNoRows)
+				mockPool.ExpectRollback()
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			mockPool, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(pgxmock.QueryMatcherEqual))
+			assert.Equal(t, nil, err)
+
+			r := repo.NewPersonRepo(&postgres.Postgres{
+				Builder: _builder,
+				Pool:    mockPool,
+			})
+
+			testCase.mockBehavior(mockPool)
+
+			got, created, err := r.Upsert(context.Background(), &person, testCase.createOnly, _actor)
+
+			switch testCase.nameTest {
+			case "created", "create_only":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, true, created)
+				assert.Equal(t, 1, got.Version)
+			case "create_only_exists":
+				assert.Equal(t, errs.ErrPreconditionFailed, err)
+			case "replaced":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, false, created)
+				assert.Equal(t, 2, got.Version)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			case "in_trash":
+				assert.Equal(t, errs.ErrConflict, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+
+			assert.Equal(t, nil, mockPool.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFormRepo_Delete(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	softDeleteSql := recordedSql("UPDATE persons_ SET deleted_at_ = now(), version_ = version_ + 1, updated_at_ = now() WHERE deleted_at_ IS NULL AND id_ = $1",
+		"$2, $3", "NULL", "id_")
+	softDeleteVersionSql := recordedSql("UPDATE persons_ SET deleted_at_ = now(), version_ = version_ + 1, updated_at_ = now() WHERE deleted_at_ IS NULL AND id_ = $1 AND version_ = $2",
+		"$3, $4", "NULL", "id_")
+
+	type mockBehavior func(ctx context.Context, id int, version int)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		id           int
+		version      int
+		mockBehavior mockBehavior
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			id:       345,
+			mockBehavior: func(ctx context.Context, id int, version int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_"}).AddRow(id).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, softDeleteSql, id, "delete", _actor).Return(pgxRows)
+			},
+		},
+		{
+			nameTest: "no_person_to_delete",
+			ctx:      context.Background(),
+			id:       345,
+			mockBehavior: func(ctx context.Context, id int, version int) {
+				mockPool.EXPECT().QueryRow(ctx, softDeleteSql, id, "delete", _actor).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			id:       345,
+			mockBehavior: func(ctx context.Context, id int, version int) {
+				mockPool.EXPECT().QueryRow(ctx, softDeleteSql, id, "delete", _actor).Return(errRow{errors.New("query_error")})
+			},
+		},
+		{
+			nameTest: "ok_version",
+			ctx:      context.Background(),
+			id:       345,
+			version:  3,
+			mockBehavior: func(ctx context.Context, id int, version int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_"}).AddRow(id).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, softDeleteVersionSql, id, version, "delete", _actor).Return(pgxRows)
+			},
+		},
+		{
+			nameTest: "stale_version",
+			ctx:      context.Background(),
+			id:       345,
+			version:  3,
+			mockBehavior: func(ctx context.Context, id int, version int) {
+				mockPool.EXPECT().QueryRow(ctx, softDeleteVersionSql, id, version, "delete", _actor).Return(errRow{pgx.ErrNoRows})
+
+				pgxRows := pgxpoolmock.NewRows([]string{"?column?"}).AddRow(1).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, "SELECT 1 FROM persons_ WHERE deleted_at_ IS NULL AND id_ = $1", id).Return(pgxRows)
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.id, testCase.version)
+
+			err := r.Delete(testCase.ctx, testCase.id, testCase.version, _actor)
+
+			switch testCase.nameTest {
+			case "ok", "ok_version":
+				assert.Equal(t, nil, err)
+			case "invalid_inputs":
+				assert.Equal(t, errs.ErrInvalidContent, err)
+			case "no_person_to_delete":
+				assert.Equal(t, errs.ErrNoContent, err)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			case "stale_version":
+				assert.Equal(t, errs.ErrPreconditionFailed, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Trash(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	type mockBehavior func(ctx context.Context)
+
+	testTable := []struct {
+		nameTest        string
+		ctx             context.Context
+		page            models.Page
+		mockBehavior    mockBehavior
+		expectedPersons []*models.Person
+		expectedNext    *models.Person
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			page:     models.Page{Limit: 1},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "name_", "address_", "work_", "age_", "version_", "updated_at_", "deleted_at_"}).AddRow(345, "qwerty1", "address1", "work1", 11, 2, _now, _now).AddRow(346, "qwerty2", "address2", "work2", 12, 2, _now, _now).ToPgxRows()
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_, deleted_at_ FROM persons_ WHERE deleted_at_ IS NOT NULL ORDER BY id_ LIMIT 2").Return(pgxRows, nil)
+			},
+			expectedPersons: []*models.Person{
+				{Id: 345, Name: "qwerty1", Address: "address1", Work: "work1", Age: 11, Version: 2, UpdatedAt: _now, DeletedAt: _now},
+			},
+			expectedNext: &models.Person{Id: 345, Name: "qwerty1", Address: "address1", Work: "work1", Age: 11, Version: 2, UpdatedAt: _now, DeletedAt: _now},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			mockBehavior: func(ctx context.Context) {
+				mockPool.EXPECT().Query(ctx, "SELECT id_, name_, address_, work_, age_, version_, updated_at_, deleted_at_ FROM persons_ WHERE deleted_at_ IS NOT NULL ORDER BY id_").Return(nil, errors.New("query_error"))
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx)
+
+			got, err := r.Trash(testCase.ctx, &testCase.page)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPersons, got.Persons)
+				assert.Equal(t, testCase.expectedNext, got.Next)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Restore(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	restoreSql := recordedSql("UPDATE persons_ SET deleted_at_ = $1, version_ = version_ + 1, updated_at_ = now() WHERE id_ = $2 AND deleted_at_ IS NOT NULL",
+		"$3, $4", _snapshot, "name_, address_, work_, age_, version_, created_at_, updated_at_")
+
+	type mockBehavior func(ctx context.Context, id int)
+
+	testTable := []struct {
+		nameTest       string
+		ctx            context.Context
+		id             int
+		mockBehavior   mockBehavior
+		expectedPerson *models.Person
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			id:       345,
+			mockBehavior: func(ctx context.Context, id int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"name_", "address_", "work_", "age_", "version_", "created_at_", "updated_at_"}).AddRow("qwerty", "address", "work", 12, 3, _now, _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, restoreSql, nil, id, "restore", _actor).Return(pgxRows)
+			},
+			expectedPerson: &models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work", Age: 12, Version: 3, CreatedAt: _now, UpdatedAt: _now},
+		},
+		{
+			nameTest: "not_in_trash",
+			ctx:      context.Background(),
+			id:       346,
+			mockBehavior: func(ctx context.Context, id int) {
+				mockPool.EXPECT().QueryRow(ctx, restoreSql, nil, id, "restore", _actor).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.id)
+
+			got, err := r.Restore(testCase.ctx, testCase.id, _actor)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedPerson, got)
+			case "not_in_trash":
+				assert.Equal(t, errs.ErrNotFound, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_Purge(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	purgeSql := recordedSql("DELETE FROM persons_ WHERE id_ = $1 AND deleted_at_ IS NOT NULL", "$2, $3", "NULL", "id_")
+
+	type mockBehavior func(ctx context.Context, id int)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		id           int
+		mockBehavior mockBehavior
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			id:       345,
+			mockBehavior: func(ctx context.Context, id int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_"}).AddRow(id).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, purgeSql, id, "purge", _actor).Return(pgxRows)
+			},
+		},
+		{
+			nameTest: "not_in_trash",
+			ctx:      context.Background(),
+			id:       346,
+			mockBehavior: func(ctx context.Context, id int) {
+				mockPool.EXPECT().QueryRow(ctx, purgeSql, id, "purge", _actor).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			id:       347,
+			mockBehavior: func(ctx context.Context, id int) {
+				mockPool.EXPECT().QueryRow(ctx, purgeSql, id, "purge", _actor).Return(errRow{errors.New("query_error")})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.id)
+
+			err := r.Purge(testCase.ctx, testCase.id, _actor)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+			case "not_in_trash":
+				assert.Equal(t, errs.ErrNotFound, err)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_History(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	historySql := "SELECT id_, version_, operation_, actor_, changed_at_, before_, after_ FROM " +
+		"(SELECT id_, version_, operation_, actor_, changed_at_, lag(after_) OVER (ORDER BY id_) AS before_, after_ FROM persons_history_ WHERE person_id_ = $1) AS history_ " +
+		"WHERE id_ < $2 ORDER BY id_ DESC LIMIT 2"
+
+	before := []byte(`{"name":"qwerty","address":"address","work":"work","age":12}`)
+	after := []byte(`{"name":"qwerty","address":"address","work":"work2","age":12}`)
+
+	type mockBehavior func(ctx context.Context)
+
+	testTable := []struct {
+		nameTest          string
+		ctx               context.Context
+		page              models.HistoryPage
+		mockBehavior      mockBehavior
+		expectedRevisions []*models.PersonRevision
+		expectedNext      *models.PersonRevision
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			page:     models.HistoryPage{Limit: 1, After: &models.PersonRevision{Revision: 10}},
+			mockBehavior: func(ctx context.Context) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "version_", "operation_", "actor_", "changed_at_", "before_", "after_"}).
+					AddRow(int64(9), 0, "delete", "user1", _now, after, []byte(nil)).
+					AddRow(int64(8), 2, "update", "user1", _now, before, after).
+					ToPgxRows()
+				mockPool.EXPECT().Query(ctx, historySql, 345, int64(10)).Return(pgxRows, nil)
+			},
+			expectedRevisions: []*models.PersonRevision{
+				{Revision: 9, PersonId: 345, Operation: models.PersonOperationDelete, Actor: "user1", ChangedAt: _now,
+					Before: &models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work2", Age: 12}},
+			},
+			expectedNext: &models.PersonRevision{Revision: 9, PersonId: 345, Operation: models.PersonOperationDelete, Actor: "user1", ChangedAt: _now,
+				Before: &models.Person{Id: 345, Name: "qwerty", Address: "address", Work: "work2", Age: 12}},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			page:     models.HistoryPage{Limit: 1, After: &models.PersonRevision{Revision: 10}},
+			mockBehavior: func(ctx context.Context) {
+				mockPool.EXPECT().Query(ctx, historySql, 345, int64(10)).Return(nil, errors.New("query_error"))
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx)
+
+			got, err := r.History(testCase.ctx, 345, &testCase.page)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedRevisions, got.Revisions)
+				assert.Equal(t, testCase.expectedNext, got.Next)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestPersonRepo_ReserveIdempotencyKey(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewPersonRepo(&db)
+
+	reserveSql := "INSERT INTO idempotency_keys_ (actor_, key_, request_hash_, expires_at_) VALUES ($1,$2,$3,$4) " +
+		"ON CONFLICT (actor_, key_) DO UPDATE SET request_hash_ = EXCLUDED.request_hash_, status_ = 0, location_ = '', etag_ = '', expires_at_ = EXCLUDED.expires_at_ " +
+		"WHERE idempotency_keys_.expires_at_ <= now() RETURNING \"key_\""
+	storedSql := "SELECT request_hash_, status_, location_, etag_, expires_at_ FROM idempotency_keys_ WHERE actor_ = $1 AND key_ = $2"
+	expiredSql := "DELETE FROM idempotency_keys_ WHERE (actor_, key_) IN (SELECT actor_, key_ FROM idempotency_keys_ WHERE expires_at_ < now() LIMIT 100)"
+
+	type mockBehavior func(ctx context.Context, key *models.IdempotencyKey)
+
+	testTable := []struct {
+		nameTest         string
+		ctx              context.Context
+		key              *models.IdempotencyKey
+		mockBehavior     mockBehavior
+		expectedKey      *models.IdempotencyKey
+		expectedReserved bool
+	}{
+		{
+			nameTest: "reserved",
+			ctx:      context.Background(),
+			key:      &models.IdempotencyKey{Actor: _actor, Key: "key1", RequestHash: "hash1", ExpiresAt: _now},
+			mockBehavior: func(ctx context.Context, key *models.IdempotencyKey) {
+				mockPool.EXPECT().Exec(ctx, expiredSql).Return(pgxmock.NewResult("DELETE", 0), nil)
+				pgxRows := pgxpoolmock.NewRows([]string{"key_"}).AddRow(key.Key).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, reserveSql, key.Actor, key.Key, key.RequestHash, key.ExpiresAt).Return(pgxRows)
+			},
+			expectedKey:      &models.IdempotencyKey{Actor: _actor, Key: "key1", RequestHash: "hash1", ExpiresAt: _now},
+			expectedReserved: true,
+		},
+		{
+			nameTest: "taken",
+			ctx:      context.Background(),
+			key:      &models.IdempotencyKey{Actor: _actor, Key: "key2", RequestHash: "hash2", ExpiresAt: _now},
+			mockBehavior: func(ctx context.Context, key *models.IdempotencyKey) {
+				mockPool.EXPECT().Exec(ctx, expiredSql).Return(pgxmock.NewResult("DELETE", 0), nil)
+				mockPool.EXPECT().QueryRow(ctx, reserveSql, key.Actor, key.Key, key.RequestHash, key.ExpiresAt).Return(errRow{pgx.ErrNoRows})
+
+				pgxRows := pgxpoolmock.NewRows([]string{"request_hash_", "status_", "location_", "etag_", "expires_at_"}).AddRow("hash1", 201, "/api/v1/persons/345", `"1"`, _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, storedSql, key.Actor, key.Key).Return(pgxRows)
+			},
+			expectedKey: &models.IdempotencyKey{Actor: _actor, Key: "key2", RequestHash: "hash1", Status: 201, Location: "/api/v1/persons/345", ETag: `"1"`, ExpiresAt: _now},
+		},
+		{
+			nameTest: "cleanup_error",
+			ctx:      context.Background(),
+			key:      &models.IdempotencyKey{Actor: _actor, Key: "key4", RequestHash: "hash4", ExpiresAt: _now},
+			mockBehavior: func(ctx context.Context, key *models.IdempotencyKey) {
+				mockPool.EXPECT().Exec(ctx, expiredSql).Return(nil, errors.New("exec_error"))
+			},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			key:      &models.IdempotencyKey{Actor: _actor, Key: "key3", RequestHash: "hash3", ExpiresAt: _now},
+			mockBehavior: func(ctx context.Context, key *models.IdempotencyKey) {
+				mockPool.EXPECT().Exec(ctx, expiredSql).Return(pgxmock.NewResult("DELETE", 0), nil)
+				mockPool.EXPECT().QueryRow(ctx, reserveSql, key.Actor, key.Key, key.RequestHash, key.ExpiresAt).Return(errRow{errors.New("query_error")})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.key)
+
+			got, reserved, err := r.ReserveIdempotencyKey(testCase.ctx, testCase.key)
+
+			switch testCase.nameTest {
+			case "reserved", "taken":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedReserved, reserved)
+				assert.Equal(t, testCase.expectedKey, got)
+			case "query_error", "cleanup_error":
 				assert.NotEqual(t, nil, err)
 			default:
 				assert.Error(t, errors.New("No case"), "No case")
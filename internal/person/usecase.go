@@ -3,12 +3,28 @@ package person
 import (
 	"bmstu-dips-lab1/models"
 	"context"
+	"time"
 )
 
 type UseCase interface {
 	Create(ctx context.Context, model *models.Person) (*models.Person, error)
-	GetAll(ctx context.Context) ([]*models.Person, error)
+	CreateMany(ctx context.Context, persons []*models.Person, mode models.BatchMode) ([]*models.PersonResult, error)
+	Import(ctx context.Context, src models.PersonSource) (*models.ImportReport, error)
+	GetAll(ctx context.Context, filter *models.PersonFilter, page *models.Page) (*models.PersonPage, error)
+	Stat(ctx context.Context, filter *models.PersonFilter) (*models.PersonStat, error)
+	ForEach(ctx context.Context, filter *models.PersonFilter, sort []models.Sort, fn func(*models.Person) error) error
+	Search(ctx context.Context, search *models.PersonSearch, page *models.SearchPage) (*models.PersonHitPage, error)
 	GetById(ctx context.Context, id int) (*models.Person, error)
-	Update(ctx context.Context, model *models.Person, toUpdate *models.Person) (*models.Person, error)
-	Delete(ctx context.Context, id int) error
+	GetAsOf(ctx context.Context, id int, asOf time.Time) (*models.Person, error)
+	Update(ctx context.Context, model *models.Person, mask models.PersonMask) (*models.Person, error)
+	Replace(ctx context.Context, model *models.Person, createOnly bool) (*models.Person, bool, error)
+	Delete(ctx context.Context, id int, version int) error
+	Trash(ctx context.Context, page *models.Page) (*models.PersonPage, error)
+	Restore(ctx context.Context, id int) (*models.Person, error)
+	Purge(ctx context.Context, id int) error
+	History(ctx context.Context, id int, page *models.HistoryPage) (*models.PersonRevisionPage, error)
+	Revert(ctx context.Context, id int, rev int64, version int) (*models.Person, error)
+	ReserveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
 }
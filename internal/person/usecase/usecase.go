@@ -1,43 +1,268 @@
 package usecase
 
 import (
+	"bmstu-dips-lab1/config"
 	"bmstu-dips-lab1/internal/person"
 	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
 	"context"
+	"fmt"
+	"time"
 )
 
 type PersonUseCase struct {
+	cfg        *config.Config
+	rules      *personRules
 	personRepo person.Repo
 }
 
-func NewPersonUseCase(personRepo person.Repo) person.UseCase {
+func NewPersonUseCase(cfg *config.Config, personRepo person.Repo) person.UseCase {
 	return &PersonUseCase{
+		cfg:        cfg,
+		rules:      newPersonRules(cfg.Validation),
 		personRepo: personRepo,
 	}
 }
 
 func (p *PersonUseCase) Create(ctx context.Context, model *models.Person) (*models.Person, error) {
-	return p.personRepo.Create(ctx, model)
+	err := p.rules.validate(model, models.FullPersonMask())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.personRepo.Create(ctx, model, p.actor(ctx))
 }
 
-func (p *PersonUseCase) GetAll(ctx context.Context) ([]*models.Person, error) {
-	return p.personRepo.GetAll(ctx)
+// CreateMany validates every person first. Invalid persons are reported in
+// their results; in the atomic mode they fail the others with
+// errs.ErrFailedDependency and nothing reaches the repo.
+func (p *PersonUseCase) CreateMany(ctx context.Context, persons []*models.Person, mode models.BatchMode) ([]*models.PersonResult, error) {
+	results := make([]*models.PersonResult, len(persons))
+	valid := make([]*models.Person, 0, len(persons))
+	validIdx := make([]int, 0, len(persons))
+
+	for i, person := range persons {
+		err := p.rules.validate(person, models.FullPersonMask())
+		if err != nil {
+			results[i] = &models.PersonResult{Person: person, Err: err}
+			continue
+		}
+
+		valid = append(valid, person)
+		validIdx = append(validIdx, i)
+	}
+
+	if len(valid) == len(persons) {
+		return p.personRepo.CreateMany(ctx, persons, mode, p.actor(ctx))
+	}
+
+	if mode == models.BatchModeAtomic {
+		for _, i := range validIdx {
+			results[i] = &models.PersonResult{Person: persons[i], Err: errs.ErrFailedDependency}
+		}
+
+		return results, nil
+	}
+
+	if len(valid) != 0 {
+		created, err := p.personRepo.CreateMany(ctx, valid, mode, p.actor(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		for j, result := range created {
+			results[validIdx[j]] = result
+		}
+	}
+
+	return results, nil
+}
+
+// Import copies valid records of src into the repo and reports the rejected
+// ones.
+func (p *PersonUseCase) Import(ctx context.Context, src models.PersonSource) (*models.ImportReport, error) {
+	report := &models.ImportReport{
+		Rejected: make([]*models.PersonRow, 0),
+	}
+
+	start := time.Now()
+
+	imported, err := p.personRepo.Import(ctx, func() (*models.Person, error) {
+		for {
+			row, err := src.Next()
+			if err != nil {
+				return nil, err
+			}
+
+			if row.Err == nil {
+				row.Err = p.rules.validate(row.Person, models.FullPersonMask())
+			}
+
+			if row.Err != nil {
+				report.Rejected = append(report.Rejected, row)
+				continue
+			}
+
+			return row.Person, nil
+		}
+	}, p.actor(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	report.Imported = imported
+	report.Duration = time.Since(start)
+
+	return report, nil
+}
+
+func (p *PersonUseCase) GetAll(ctx context.Context, filter *models.PersonFilter, page *models.Page) (*models.PersonPage, error) {
+	return p.personRepo.GetAll(ctx, filter, page)
+}
+
+func (p *PersonUseCase) Stat(ctx context.Context, filter *models.PersonFilter) (*models.PersonStat, error) {
+	return p.personRepo.Stat(ctx, filter)
+}
+
+func (p *PersonUseCase) ForEach(ctx context.Context, filter *models.PersonFilter, sort []models.Sort, fn func(*models.Person) error) error {
+	return p.personRepo.ForEach(ctx, filter, sort, fn)
+}
+
+func (p *PersonUseCase) Search(ctx context.Context, search *models.PersonSearch, page *models.SearchPage) (*models.PersonHitPage, error) {
+	return p.personRepo.Search(ctx, search, page)
 }
 
 func (p *PersonUseCase) GetById(ctx context.Context, id int) (*models.Person, error) {
 	return p.personRepo.GetById(ctx, id)
 }
 
-func (p *PersonUseCase) Update(ctx context.Context, model *models.Person, toUpdate *models.Person) (*models.Person, error) {
-	_, err := p.personRepo.Update(ctx, model, toUpdate)
+func (p *PersonUseCase) GetAsOf(ctx context.Context, id int, asOf time.Time) (*models.Person, error) {
+	return p.personRepo.GetAsOf(ctx, id, asOf)
+}
+
+// Update writes the fields of mask, an empty mask changes nothing.
+func (p *PersonUseCase) Update(ctx context.Context, model *models.Person, mask models.PersonMask) (*models.Person, error) {
+	if len(mask) == 0 {
+		return p.personRepo.GetById(ctx, model.Id)
+	}
+
+	err := p.rules.validate(model, mask)
 	if err != nil {
 		return nil, err
 	}
 
-	// crutch
-	return p.personRepo.GetById(ctx, model.Id)
+	return p.personRepo.Update(ctx, model, mask, p.actor(ctx))
+}
+
+// Replace overwrites the whole person and reports whether it was created,
+// which happens only with upsert on PUT enabled in the config. A versioned
+// replace expects the person to exist, so it never creates one, and a
+// createOnly one fails when the person exists.
+func (p *PersonUseCase) Replace(ctx context.Context, model *models.Person, createOnly bool) (*models.Person, bool, error) {
+	err := p.rules.validate(model, models.FullPersonMask())
+	if err != nil {
+		return nil, false, err
+	}
+
+	if p.cfg.Persons.UpsertOnPut && model.Version == 0 {
+		return p.personRepo.Upsert(ctx, model, createOnly, p.actor(ctx))
+	}
+
+	replaced, err := p.personRepo.Replace(ctx, model, p.actor(ctx))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return replaced, false, nil
+}
+
+func (p *PersonUseCase) Delete(ctx context.Context, id int, version int) error {
+	return p.personRepo.Delete(ctx, id, version, p.actor(ctx))
+}
+
+func (p *PersonUseCase) Trash(ctx context.Context, page *models.Page) (*models.PersonPage, error) {
+	return p.personRepo.Trash(ctx, page)
+}
+
+func (p *PersonUseCase) Restore(ctx context.Context, id int) (*models.Person, error) {
+	return p.personRepo.Restore(ctx, id, p.actor(ctx))
+}
+
+func (p *PersonUseCase) Purge(ctx context.Context, id int) error {
+	return p.personRepo.Purge(ctx, id, p.actor(ctx))
+}
+
+func (p *PersonUseCase) History(ctx context.Context, id int, page *models.HistoryPage) (*models.PersonRevisionPage, error) {
+	return p.personRepo.History(ctx, id, page)
+}
+
+// Revert writes the fields revision rev left back to the person, as of
+// version when it is not zero. A person in the trash is restored, a purged
+// one is gone for good. The revert is a revision of its own.
+func (p *PersonUseCase) Revert(ctx context.Context, id int, rev int64, version int) (*models.Person, error) {
+	revision, err := p.personRepo.GetRevision(ctx, id, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision.After == nil {
+		return nil, errs.Invalid("revision %d removed the person, there is nothing to revert to", rev)
+	}
+
+	model := *revision.After
+	model.Version = version
+
+	err = p.rules.validate(&model, models.FullPersonMask())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.personRepo.Revert(ctx, &model, p.actor(ctx))
+}
+
+// actor is the user the auth middleware put into the context under the
+// configured key.
+func (p *PersonUseCase) actor(ctx context.Context) string {
+	switch user := ctx.Value(p.cfg.Server.CtxUserKey).(type) {
+	case string:
+		if user != "" {
+			return user
+		}
+	case fmt.Stringer:
+		return user.String()
+	}
+
+	return models.AnonymousActor
+}
+
+// ReserveIdempotencyKey reserves key of the actor of ctx for the configured
+// TTL. A key already taken gives back its stored response, or errs.ErrIdempotencyKeyReused
+// when it was taken by another request and errs.ErrConflict while that
+// request is still in flight.
+func (p *PersonUseCase) ReserveIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	key.Actor = p.actor(ctx)
+	key.ExpiresAt = time.Now().Add(p.cfg.Persons.IdempotencyTTL)
+
+	stored, reserved, err := p.personRepo.ReserveIdempotencyKey(ctx, key)
+	if err != nil || reserved {
+		return stored, reserved, err
+	}
+
+	if stored.RequestHash != key.RequestHash {
+		return nil, false, errs.ErrIdempotencyKeyReused
+	}
+
+	if stored.Status == 0 {
+		return nil, false, errs.ErrConflict
+	}
+
+	return stored, false, nil
+}
+
+func (p *PersonUseCase) CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	return p.personRepo.CompleteIdempotencyKey(ctx, key)
 }
 
-func (p *PersonUseCase) Delete(ctx context.Context, id int) error {
-	return p.personRepo.Delete(ctx, id)
+func (p *PersonUseCase) ReleaseIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	return p.personRepo.ReleaseIdempotencyKey(ctx, key)
 }
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// persons_ columns are VARCHAR(64), no config may let longer strings through
+const maxPersonFieldLen = 64
+
+type fieldRule struct {
+	config.FieldRule
+	regex *regexp.Regexp
+}
+
+type personRules struct {
+	name    fieldRule
+	address fieldRule
+	work    fieldRule
+	age     fieldRule
+}
+
+// newPersonRules takes the rules of the config, its regexes are already
+// checked by config.ParseConfig.
+func newPersonRules(cfg config.ValidationConfig) *personRules {
+	rule := func(r config.FieldRule) fieldRule {
+		res := fieldRule{FieldRule: r}
+		if r.Regex != "" {
+			res.regex = regexp.MustCompile(r.Regex)
+		}
+
+		return res
+	}
+
+	return &personRules{
+		name:    rule(cfg.Name),
+		address: rule(cfg.Address),
+		work:    rule(cfg.Work),
+		age:     rule(cfg.Age),
+	}
+}
+
+// validate normalizes the strings of model in place and checks the fields
+// of mask. Every broken field is reported, not only the first one.
+func (r *personRules) validate(model *models.Person, mask models.PersonMask) error {
+	fields := make(map[string]string)
+
+	strs := []struct {
+		field models.PersonField
+		rule  *fieldRule
+		value *string
+	}{
+		{models.PersonFieldName, &r.name, &model.Name},
+		{models.PersonFieldAddress, &r.address, &model.Address},
+		{models.PersonFieldWork, &r.work, &model.Work},
+	}
+
+	for _, s := range strs {
+		if !mask.Has(s.field) {
+			continue
+		}
+
+		*s.value = norm.NFC.String(strings.TrimSpace(*s.value))
+
+		msg := s.rule.checkString(*s.value)
+		if msg != "" {
+			fields[string(s.field)] = msg
+		}
+	}
+
+	if mask.Has(models.PersonFieldAge) {
+		msg := r.age.checkInt(model.Age)
+		if msg != "" {
+			fields[string(models.PersonFieldAge)] = msg
+		}
+	}
+
+	if len(fields) != 0 {
+		return errs.InvalidFields(fields)
+	}
+
+	return nil
+}
+
+func (r *fieldRule) checkString(value string) string {
+	if value == "" {
+		if r.Required {
+			return "is required"
+		}
+
+		return ""
+	}
+
+	length := utf8.RuneCountInString(value)
+
+	switch {
+	case r.Min != nil && length < *r.Min:
+		return fmt.Sprintf("must be at least %d characters", *r.Min)
+	case r.Max != nil && length > *r.Max:
+		return fmt.Sprintf("must be at most %d characters", *r.Max)
+	case length > maxPersonFieldLen:
+		return fmt.Sprintf("must be at most %d characters", maxPersonFieldLen)
+	case r.regex != nil && !r.regex.MatchString(value):
+		return "has invalid format"
+	}
+
+	return ""
+}
+
+// checkInt treats zero as a missing value, as the binding of requests does.
+func (r *fieldRule) checkInt(value int) string {
+	switch {
+	case value == 0 && r.Required:
+		return "is required"
+	case r.Min != nil && value < *r.Min:
+		return fmt.Sprintf("must be at least %d", *r.Min)
+	case r.Max != nil && value > *r.Max:
+		return fmt.Sprintf("must be at most %d", *r.Max)
+	}
+
+	return ""
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestPersonRules_Validate(t *testing.T) {
+	t.Parallel()
+
+	// 40 runes, 80 bytes
+	cyrillic := strings.Repeat("Ж", 40)
+	tooLong := strings.Repeat("a", maxPersonFieldLen+1)
+
+	testTable := []struct {
+		nameTest       string
+		cfg            config.ValidationConfig
+		person         models.Person
+		mask           models.PersonMask
+		expectedPerson models.Person
+		expectedFields map[string]string
+	}{
+		{
+			nameTest:       "trim_and_nfc",
+			person:         models.Person{Name: "  Jose\u0301 ", Address: "\tMoscow\n", Work: " work1 "},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{Name: "Jos\u00e9", Address: "Moscow", Work: "work1"},
+		},
+		{
+			nameTest:       "runes_not_bytes",
+			cfg:            config.ValidationConfig{Name: config.FieldRule{Max: intPtr(40)}},
+			person:         models.Person{Name: cyrillic},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{Name: cyrillic},
+		},
+		{
+			nameTest:       "runes_over_max",
+			cfg:            config.ValidationConfig{Name: config.FieldRule{Max: intPtr(39)}},
+			person:         models.Person{Name: cyrillic},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{Name: cyrillic},
+			expectedFields: map[string]string{"name": "must be at most 39 characters"},
+		},
+		{
+			nameTest:       "schema_cap_over_config_max",
+			cfg:            config.ValidationConfig{Address: config.FieldRule{Max: intPtr(100)}},
+			person:         models.Person{Address: tooLong},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{Address: tooLong},
+			expectedFields: map[string]string{"address": "must be at most 64 characters"},
+		},
+		{
+			nameTest:       "required",
+			cfg:            config.ValidationConfig{Name: config.FieldRule{Required: true}, Age: config.FieldRule{Required: true}},
+			person:         models.Person{Name: "   "},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{},
+			expectedFields: map[string]string{"name": "is required", "age": "is required"},
+		},
+		{
+			nameTest:       "age_bounds",
+			cfg:            config.ValidationConfig{Age: config.FieldRule{Min: intPtr(1), Max: intPtr(150)}},
+			person:         models.Person{Age: 151},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{Age: 151},
+			expectedFields: map[string]string{"age": "must be at most 150"},
+		},
+		{
+			nameTest:       "regex",
+			cfg:            config.ValidationConfig{Work: config.FieldRule{Regex: "^[a-z]+[0-9]*$"}},
+			person:         models.Person{Work: "Work1"},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{Work: "Work1"},
+			expectedFields: map[string]string{"work": "has invalid format"},
+		},
+		{
+			nameTest:       "regex_skips_empty",
+			cfg:            config.ValidationConfig{Work: config.FieldRule{Regex: "^[a-z]+$"}},
+			person:         models.Person{},
+			mask:           models.FullPersonMask(),
+			expectedPerson: models.Person{},
+		},
+		{
+			nameTest: "partial_mask",
+			cfg: config.ValidationConfig{
+				Name:    config.FieldRule{Min: intPtr(2)},
+				Address: config.FieldRule{Required: true},
+				Age:     config.FieldRule{Required: true},
+			},
+			person:         models.Person{Name: " I ", Address: " ", Work: tooLong},
+			mask:           models.NewPersonMask(models.PersonFieldName),
+			expectedPerson: models.Person{Name: "I", Address: " ", Work: tooLong},
+			expectedFields: map[string]string{"name": "must be at least 2 characters"},
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			t.Parallel()
+
+			person := testCase.person
+			err := newPersonRules(testCase.cfg).validate(&person, testCase.mask)
+
+			assert.Equal(t, testCase.expectedPerson, person)
+
+			if testCase.expectedFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var invalid *errs.InvalidError
+			assert.True(t, errors.As(err, &invalid))
+			assert.Equal(t, testCase.expectedFields, invalid.Fields)
+		})
+	}
+}
@@ -1,22 +1,49 @@
 package server
 
 import (
+	"bmstu-dips-lab1/internal/middleware"
 	h "bmstu-dips-lab1/internal/person/delivery/http"
 	"bmstu-dips-lab1/internal/person/repo"
 	"bmstu-dips-lab1/internal/person/usecase"
+	uh "bmstu-dips-lab1/internal/user/delivery/http"
+	urepo "bmstu-dips-lab1/internal/user/repo"
+	uusecase "bmstu-dips-lab1/internal/user/usecase"
+	"errors"
 )
 
 func (s *Server) MapHandlers() error {
+	mw, err := middleware.NewMiddlewareManager(s.cfg)
+	if err != nil {
+		return err
+	}
+
 	pRepo := repo.NewPersonRepo(s.db)
-	pUC := usecase.NewPersonUseCase(pRepo)
-	pH := h.NewPersonHandlers(pUC)
+	pUC := usecase.NewPersonUseCase(s.cfg, pRepo)
+	pH := h.NewPersonHandlers(s.cfg, pUC)
 
 	api := s.router.Group("/api")
 
+	if s.cfg.Users.Enabled {
+		if s.cfg.Server.JwtSecretKey == "" {
+			return errors.New("users are enabled without server.jwtsecretkey to sign their tokens, " +
+				"set SERVER_JWTSECRETKEY or USERS_ENABLED=false")
+		}
+
+		uRepo := urepo.NewUserRepo(s.db)
+		uUC := uusecase.NewUserUseCase(s.cfg, uRepo)
+		uH := uh.NewUserHandlers(s.cfg, uUC)
+
+		// auth stays out of the v1 group, the tokens v1 requires are got there
+		auth := api.Group("/v1/auth")
+		uh.MapUserRoutes(auth, uH)
+	}
+
 	v1 := api.Group("/v1")
+	v1.Use(mw.AuthJWTMiddleware())
 
 	persons := v1.Group("/persons")
-	h.MapPersonRoutes(persons, pH)
+	h.MapPersonRoutes(persons, pH, mw)
+	h.MapPersonVerbRoutes(v1, pH)
 
 	return nil
 }
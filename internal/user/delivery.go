@@ -0,0 +1,9 @@
+package user
+
+import "github.com/gin-gonic/gin"
+
+type Handlers interface {
+	Register() gin.HandlerFunc
+	Login() gin.HandlerFunc
+	Refresh() gin.HandlerFunc
+}
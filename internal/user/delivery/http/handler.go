@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/internal/user"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenTypeBearer = "Bearer"
+
+type UserCredentialsRequest struct {
+	Login    string `json:"login" binding:"required,max=64"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type UserResponse struct {
+	Id    int    `json:"id"`
+	Login string `json:"login"`
+}
+
+// TokenResponse is shaped as the OAuth 2.0 token response, expires_in is
+// the lifetime of the access token in seconds
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type UserHandlers struct {
+	cfg    *config.Config
+	userUC user.UseCase
+}
+
+func NewUserHandlers(cfg *config.Config, userUC user.UseCase) user.Handlers {
+	return &UserHandlers{
+		cfg:    cfg,
+		userUC: userUC,
+	}
+}
+
+func (u *UserHandlers) Register() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := new(UserCredentialsRequest)
+
+		err := c.ShouldBindJSON(request)
+		if err != nil {
+			errs.Abort(c, errs.FromBinding(err))
+			return
+		}
+
+		registered, err := u.userUC.Register(c, UserCredentialsRequestToBL(request))
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, UserBLToResponse(registered))
+	}
+}
+
+func (u *UserHandlers) Login() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := new(UserCredentialsRequest)
+
+		err := c.ShouldBindJSON(request)
+		if err != nil {
+			errs.Abort(c, errs.FromBinding(err))
+			return
+		}
+
+		tokens, err := u.userUC.Login(c, UserCredentialsRequestToBL(request))
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, UserTokensBLToResponse(tokens))
+	}
+}
+
+func (u *UserHandlers) Refresh() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := new(RefreshRequest)
+
+		err := c.ShouldBindJSON(request)
+		if err != nil {
+			errs.Abort(c, errs.FromBinding(err))
+			return
+		}
+
+		tokens, err := u.userUC.Refresh(c, request.RefreshToken)
+		if err != nil {
+			errs.Abort(c, err)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, UserTokensBLToResponse(tokens))
+	}
+}
+
+func UserCredentialsRequestToBL(request *UserCredentialsRequest) *models.UserCredentials {
+	return &models.UserCredentials{
+		Login:    request.Login,
+		Password: request.Password,
+	}
+}
+
+func UserBLToResponse(modelBL *models.User) *UserResponse {
+	return &UserResponse{
+		Id:    modelBL.Id,
+		Login: modelBL.Login,
+	}
+}
+
+func UserTokensBLToResponse(tokens *models.UserTokens) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokenTypeBearer,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}
+}
@@ -0,0 +1,13 @@
+package http
+
+import (
+	"bmstu-dips-lab1/internal/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MapUserRoutes(authGroup *gin.RouterGroup, h user.Handlers) {
+	authGroup.POST("/register", h.Register())
+	authGroup.POST("/login", h.Login())
+	authGroup.POST("/refresh", h.Refresh())
+}
@@ -0,0 +1,12 @@
+package user
+
+import (
+	"bmstu-dips-lab1/models"
+	"context"
+)
+
+type Repo interface {
+	Create(ctx context.Context, modelBL *models.User) (*models.User, error)
+	GetById(ctx context.Context, id int) (*models.User, error)
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+}
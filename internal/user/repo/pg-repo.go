@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"bmstu-dips-lab1/internal/user"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"bmstu-dips-lab1/pkg/postgres"
+	"context"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx"
+)
+
+type UserDB struct {
+	id                  int
+	login, passwordHash string
+	createdAt           time.Time
+}
+
+type UserRepo struct {
+	*postgres.Postgres
+}
+
+func NewUserRepo(db *postgres.Postgres) user.Repo {
+	return &UserRepo{db}
+}
+
+// Create inserts the user, a taken login is errs.ErrLoginExists.
+func (u *UserRepo) Create(ctx context.Context, modelBL *models.User) (*models.User, error) {
+	modelDB := UserBLToDB(modelBL)
+
+	sql, args, err := u.Builder.
+		Insert("users_").
+		Columns("login_, password_hash_").
+		Values(modelDB.login, modelDB.passwordHash).
+		Suffix("ON CONFLICT (login_) DO NOTHING RETURNING \"id_\", \"created_at_\"").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.id, &modelDB.createdAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, errs.ErrLoginExists
+		}
+
+		return nil, err
+	}
+
+	return UserDBToBL(modelDB), nil
+}
+
+func (u *UserRepo) GetById(ctx context.Context, id int) (*models.User, error) {
+	return u.get(ctx, squirrel.Eq{"id_": id})
+}
+
+func (u *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	return u.get(ctx, squirrel.Eq{"login_": login})
+}
+
+func (u *UserRepo) get(ctx context.Context, where squirrel.Eq) (*models.User, error) {
+	sql, args, err := u.Builder.
+		Select("id_, login_, password_hash_, created_at_").
+		From("users_").
+		Where(where).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	modelDB := UserDB{}
+	err = u.Pool.QueryRow(ctx, sql, args...).Scan(&modelDB.id, &modelDB.login, &modelDB.passwordHash, &modelDB.createdAt)
+	if err != nil {
+		if err.Error() == pgx.ErrNoRows.Error() {
+			return nil, errs.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return UserDBToBL(&modelDB), nil
+}
+
+func UserDBToBL(modelDB *UserDB) *models.User {
+	return &models.User{
+		Id:           modelDB.id,
+		Login:        modelDB.login,
+		PasswordHash: modelDB.passwordHash,
+		CreatedAt:    modelDB.createdAt,
+	}
+}
+
+func UserBLToDB(modelBL *models.User) *UserDB {
+	return &UserDB{
+		id:           modelBL.Id,
+		login:        modelBL.Login,
+		passwordHash: modelBL.PasswordHash,
+		createdAt:    modelBL.CreatedAt,
+	}
+}
@@ -0,0 +1,239 @@
+package repo_test
+
+import (
+	"bmstu-dips-lab1/internal/user/repo"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"bmstu-dips-lab1/pkg/postgres"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_builder = squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+	_now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// errRow is a row of QueryRow that fails to scan, like one that was not found
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
+func TestUserRepo_Create(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewUserRepo(&db)
+
+	createSql := "INSERT INTO users_ (login_, password_hash_) VALUES ($1,$2) ON CONFLICT (login_) DO NOTHING RETURNING \"id_\", \"created_at_\""
+
+	type mockBehavior func(ctx context.Context, user *models.User)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		user         models.User
+		mockBehavior mockBehavior
+		expectedUser models.User
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			user:     models.User{Login: "user1", PasswordHash: "hash1"},
+			mockBehavior: func(ctx context.Context, user *models.User) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "created_at_"}).AddRow(7, _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, createSql, user.Login, user.PasswordHash).Return(pgxRows)
+			},
+			expectedUser: models.User{Id: 7, Login: "user1", PasswordHash: "hash1", CreatedAt: _now},
+		},
+		{
+			nameTest: "login_exists",
+			ctx:      context.Background(),
+			user:     models.User{Login: "user2", PasswordHash: "hash2"},
+			mockBehavior: func(ctx context.Context, user *models.User) {
+				mockPool.EXPECT().QueryRow(ctx, createSql, user.Login, user.PasswordHash).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+		{
+			nameTest: "query_error",
+			ctx:      context.Background(),
+			user:     models.User{Login: "user3", PasswordHash: "hash3"},
+			mockBehavior: func(ctx context.Context, user *models.User) {
+				mockPool.EXPECT().QueryRow(ctx, createSql, user.Login, user.PasswordHash).Return(errRow{errors.New("query_error")})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, &testCase.user)
+
+			got, err := r.Create(testCase.ctx, &testCase.user)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedUser, *got)
+			case "login_exists":
+				assert.Equal(t, errs.ErrLoginExists, err)
+			case "query_error":
+				assert.NotEqual(t, nil, err)
+				assert.NotEqual(t, errs.ErrLoginExists, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestUserRepo_GetByLogin(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewUserRepo(&db)
+
+	getSql := "SELECT id_, login_, password_hash_, created_at_ FROM users_ WHERE login_ = $1"
+
+	type mockBehavior func(ctx context.Context, login string)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		login        string
+		mockBehavior mockBehavior
+		expectedUser models.User
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			login:    "user1",
+			mockBehavior: func(ctx context.Context, login string) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "login_", "password_hash_", "created_at_"}).AddRow(7, login, "hash1", _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, getSql, login).Return(pgxRows)
+			},
+			expectedUser: models.User{Id: 7, Login: "user1", PasswordHash: "hash1", CreatedAt: _now},
+		},
+		{
+			nameTest: "not_found",
+			ctx:      context.Background(),
+			login:    "user2",
+			mockBehavior: func(ctx context.Context, login string) {
+				mockPool.EXPECT().QueryRow(ctx, getSql, login).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.login)
+
+			got, err := r.GetByLogin(testCase.ctx, testCase.login)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedUser, *got)
+			case "not_found":
+				assert.Equal(t, errs.ErrNotFound, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
+
+func TestUserRepo_GetById(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+
+	db := postgres.Postgres{
+		Builder: _builder,
+		Pool:    mockPool,
+	}
+
+	r := repo.NewUserRepo(&db)
+
+	getSql := "SELECT id_, login_, password_hash_, created_at_ FROM users_ WHERE id_ = $1"
+
+	type mockBehavior func(ctx context.Context, id int)
+
+	testTable := []struct {
+		nameTest     string
+		ctx          context.Context
+		id           int
+		mockBehavior mockBehavior
+		expectedUser models.User
+	}{
+		{
+			nameTest: "ok",
+			ctx:      context.Background(),
+			id:       7,
+			mockBehavior: func(ctx context.Context, id int) {
+				pgxRows := pgxpoolmock.NewRows([]string{"id_", "login_", "password_hash_", "created_at_"}).AddRow(id, "user1", "hash1", _now).ToPgxRows()
+				pgxRows.Next()
+				mockPool.EXPECT().QueryRow(ctx, getSql, id).Return(pgxRows)
+			},
+			expectedUser: models.User{Id: 7, Login: "user1", PasswordHash: "hash1", CreatedAt: _now},
+		},
+		{
+			nameTest: "not_found",
+			ctx:      context.Background(),
+			id:       8,
+			mockBehavior: func(ctx context.Context, id int) {
+				mockPool.EXPECT().QueryRow(ctx, getSql, id).Return(errRow{pgx.ErrNoRows})
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			testCase.mockBehavior(testCase.ctx, testCase.id)
+
+			got, err := r.GetById(testCase.ctx, testCase.id)
+
+			switch testCase.nameTest {
+			case "ok":
+				assert.Equal(t, nil, err)
+				assert.Equal(t, testCase.expectedUser, *got)
+			case "not_found":
+				assert.Equal(t, errs.ErrNotFound, err)
+			default:
+				assert.Error(t, errors.New("No case"), "No case")
+			}
+		})
+	}
+}
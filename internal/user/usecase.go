@@ -0,0 +1,12 @@
+package user
+
+import (
+	"bmstu-dips-lab1/models"
+	"context"
+)
+
+type UseCase interface {
+	Register(ctx context.Context, credentials *models.UserCredentials) (*models.User, error)
+	Login(ctx context.Context, credentials *models.UserCredentials) (*models.UserTokens, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.UserTokens, error)
+}
@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/internal/user"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores what goes past it
+const maxPasswordBytes = 72
+
+var errNoSecretKey = errors.New("server.jwtsecretkey is not set, tokens cannot be issued")
+
+type UserUseCase struct {
+	cfg      *config.Config
+	userRepo user.Repo
+	// compared against when the login is unknown
+	dummyHash []byte
+}
+
+func NewUserUseCase(cfg *config.Config, userRepo user.Repo) user.UseCase {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), cfg.Users.PasswordCost)
+
+	return &UserUseCase{
+		cfg:       cfg,
+		userRepo:  userRepo,
+		dummyHash: dummyHash,
+	}
+}
+
+func (u *UserUseCase) Register(ctx context.Context, credentials *models.UserCredentials) (*models.User, error) {
+	if len(credentials.Password) > maxPasswordBytes {
+		return nil, errs.InvalidFields(map[string]string{"password": fmt.Sprintf("must be at most %d bytes", maxPasswordBytes)})
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), u.cfg.Users.PasswordCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.userRepo.Create(ctx, &models.User{Login: credentials.Login, PasswordHash: string(hash)})
+}
+
+// Login gives tokens for the credentials. An unknown login is
+// errs.ErrInvalidPassword as a wrong password is, and a hash is compared
+// anyway so that the time it takes does not tell them apart either.
+func (u *UserUseCase) Login(ctx context.Context, credentials *models.UserCredentials) (*models.UserTokens, error) {
+	found, err := u.userRepo.GetByLogin(ctx, credentials.Login)
+	if err != nil && !errors.Is(err, errs.ErrNotFound) {
+		return nil, err
+	}
+
+	hash := u.dummyHash
+	if found != nil {
+		hash = []byte(found.PasswordHash)
+	}
+
+	err = bcrypt.CompareHashAndPassword(hash, []byte(credentials.Password))
+	if err != nil || found == nil {
+		return nil, errs.ErrInvalidPassword
+	}
+
+	return u.issue(found)
+}
+
+// Refresh gives new tokens for a refresh token of a user that is still
+// there. The old refresh token stays valid until it expires.
+func (u *UserUseCase) Refresh(ctx context.Context, refreshToken string) (*models.UserTokens, error) {
+	if u.cfg.Server.JwtSecretKey == "" {
+		return nil, errNoSecretKey
+	}
+
+	claims := &models.UserClaims{}
+	_, err := jwt.ParseWithClaims(refreshToken, claims,
+		func(*jwt.Token) (interface{}, error) { return []byte(u.cfg.Server.JwtSecretKey), nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidAccessToken, err)
+	}
+
+	if claims.TokenType != models.TokenTypeRefresh {
+		return nil, fmt.Errorf("%w: not a refresh token", errs.ErrInvalidAccessToken)
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: subject is not a user", errs.ErrInvalidAccessToken)
+	}
+
+	found, err := u.userRepo.GetById(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrNotFound) {
+			return nil, fmt.Errorf("%w: user is gone", errs.ErrInvalidAccessToken)
+		}
+
+		return nil, err
+	}
+
+	return u.issue(found)
+}
+
+// issue signs an access and a refresh token of the user with the HS256
+// secret, the auth middleware accepts only the first.
+func (u *UserUseCase) issue(modelBL *models.User) (*models.UserTokens, error) {
+	if u.cfg.Server.JwtSecretKey == "" {
+		return nil, errNoSecretKey
+	}
+
+	access, err := u.sign(modelBL, models.TokenTypeAccess, u.cfg.Users.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := u.sign(modelBL, models.TokenTypeRefresh, u.cfg.Users.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserTokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    u.cfg.Users.AccessTokenTTL,
+	}, nil
+}
+
+func (u *UserUseCase) sign(modelBL *models.User, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := &models.UserClaims{
+		Login:     modelBL.Login,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(modelBL.Id),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(u.cfg.Server.JwtSecretKey))
+}
@@ -0,0 +1,220 @@
+package usecase_test
+
+import (
+	"bmstu-dips-lab1/config"
+	"bmstu-dips-lab1/internal/user/usecase"
+	"bmstu-dips-lab1/models"
+	"bmstu-dips-lab1/pkg/errs"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	_secret   = "secret"
+	_login    = "ivan"
+	_password = "password1"
+)
+
+// fakeRepo keeps users by id, a missing one is errs.ErrNotFound as in the
+// postgres repo.
+type fakeRepo map[int]*models.User
+
+func (r fakeRepo) Create(ctx context.Context, modelBL *models.User) (*models.User, error) {
+	created := *modelBL
+	created.Id = len(r) + 1
+	r[created.Id] = &created
+	return &created, nil
+}
+
+func (r fakeRepo) GetById(ctx context.Context, id int) (*models.User, error) {
+	if found, ok := r[id]; ok {
+		return found, nil
+	}
+	return nil, errs.ErrNotFound
+}
+
+func (r fakeRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	for _, found := range r {
+		if found.Login == login {
+			return found, nil
+		}
+	}
+	return nil, errs.ErrNotFound
+}
+
+func newConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{JwtSecretKey: _secret},
+		Users: config.UsersConfig{
+			Enabled:         true,
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 720 * time.Hour,
+			PasswordCost:    bcrypt.MinCost,
+		},
+	}
+}
+
+func parseClaims(t *testing.T, token string) *models.UserClaims {
+	t.Helper()
+
+	claims := &models.UserClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return []byte(_secret), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return claims
+}
+
+func signRefresh(t *testing.T, claims *models.UserClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(_secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest    string
+		credentials models.UserCredentials
+		expectedErr error
+	}{
+		{
+			nameTest:    "ok",
+			credentials: models.UserCredentials{Login: _login, Password: _password},
+		},
+		{
+			nameTest:    "unknown_login",
+			credentials: models.UserCredentials{Login: "petr", Password: _password},
+			expectedErr: errs.ErrInvalidPassword,
+		},
+		{
+			nameTest:    "wrong_password",
+			credentials: models.UserCredentials{Login: _login, Password: "password2"},
+			expectedErr: errs.ErrInvalidPassword,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := newConfig()
+			uc := usecase.NewUserUseCase(cfg, fakeRepo{})
+
+			registered, err := uc.Register(context.Background(), &models.UserCredentials{Login: _login, Password: _password})
+			assert.NoError(t, err)
+
+			before := time.Now().Truncate(time.Second)
+			tokens, err := uc.Login(context.Background(), &testCase.credentials)
+
+			if testCase.expectedErr != nil {
+				assert.ErrorIs(t, err, testCase.expectedErr)
+				assert.Nil(t, tokens)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, cfg.Users.AccessTokenTTL, tokens.ExpiresIn)
+
+			for token, expected := range map[string]struct {
+				tokenType string
+				ttl       time.Duration
+			}{
+				tokens.AccessToken:  {models.TokenTypeAccess, cfg.Users.AccessTokenTTL},
+				tokens.RefreshToken: {models.TokenTypeRefresh, cfg.Users.RefreshTokenTTL},
+			} {
+				claims := parseClaims(t, token)
+
+				assert.Equal(t, expected.tokenType, claims.TokenType)
+				assert.Equal(t, strconv.Itoa(registered.Id), claims.Subject)
+				assert.Equal(t, _login, claims.Login)
+				assert.WithinRange(t, claims.ExpiresAt.Time, before.Add(expected.ttl), time.Now().Add(expected.ttl))
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+
+	inHour := jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	testTable := []struct {
+		nameTest    string
+		claims      *models.UserClaims
+		expectedErr error
+	}{
+		{
+			nameTest: "ok",
+			claims: &models.UserClaims{
+				TokenType:        models.TokenTypeRefresh,
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "1", ExpiresAt: inHour},
+			},
+		},
+		{
+			nameTest: "access_token",
+			claims: &models.UserClaims{
+				TokenType:        models.TokenTypeAccess,
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "1", ExpiresAt: inHour},
+			},
+			expectedErr: errs.ErrInvalidAccessToken,
+		},
+		{
+			nameTest: "user_gone",
+			claims: &models.UserClaims{
+				TokenType:        models.TokenTypeRefresh,
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "2", ExpiresAt: inHour},
+			},
+			expectedErr: errs.ErrInvalidAccessToken,
+		},
+		{
+			nameTest: "expired",
+			claims: &models.UserClaims{
+				TokenType: models.TokenTypeRefresh,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "1",
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+				},
+			},
+			expectedErr: errs.ErrInvalidAccessToken,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			t.Parallel()
+
+			repo := fakeRepo{1: {Id: 1, Login: _login}}
+			uc := usecase.NewUserUseCase(newConfig(), repo)
+
+			tokens, err := uc.Refresh(context.Background(), signRefresh(t, testCase.claims))
+
+			if testCase.expectedErr != nil {
+				assert.ErrorIs(t, err, testCase.expectedErr)
+				assert.Nil(t, tokens)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, models.TokenTypeAccess, parseClaims(t, tokens.AccessToken).TokenType)
+			assert.Equal(t, "1", parseClaims(t, tokens.RefreshToken).Subject)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+type BatchMode string
+
+const (
+	// BatchModeAtomic creates all items or none of them
+	BatchModeAtomic BatchMode = "atomic"
+	// BatchModeBestEffort creates every item it can and reports the rest
+	BatchModeBestEffort BatchMode = "best_effort"
+)
+
+// PersonResult is the outcome of a single batch item: the created person
+// or the reason it was not created.
+type PersonResult struct {
+	Person *Person
+	Err    error
+}
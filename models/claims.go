@@ -0,0 +1,27 @@
+package models
+
+import "github.com/golang-jwt/jwt/v5"
+
+// Types of the tokens the API issues, tokens issued elsewhere may have
+// none.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// UserClaims are the claims of an access token, the subject is the user id.
+type UserClaims struct {
+	Login     string `json:"login,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// String names the user in the person history: the login, the subject
+// when the token has none.
+func (c *UserClaims) String() string {
+	if c.Login != "" {
+		return c.Login
+	}
+
+	return c.Subject
+}
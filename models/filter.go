@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PersonFilter narrows a persons listing. Nil fields are not applied.
+type PersonFilter struct {
+	Name            *string
+	Work            *string
+	AddressContains *string
+	AgeMin          *int
+	AgeMax          *int
+	// AsOf lists the persons as their history has them at that instant
+	AsOf *time.Time
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type PersonOperation string
+
+const (
+	PersonOperationCreate  PersonOperation = "create"
+	PersonOperationUpdate  PersonOperation = "update"
+	PersonOperationDelete  PersonOperation = "delete"
+	PersonOperationRestore PersonOperation = "restore"
+	PersonOperationPurge   PersonOperation = "purge"
+	PersonOperationRevert  PersonOperation = "revert"
+)
+
+// AnonymousActor makes the changes nobody is known to have made.
+const AnonymousActor = "anonymous"
+
+// PersonRevision is a recorded write of a person. After is the person as
+// the write left it, nil when the write took it away. Before is what the
+// previous revision left, nil for the first one. Version is the one the
+// write left, the last one of the person for a purge.
+type PersonRevision struct {
+	Revision  int64
+	PersonId  int
+	Version   int
+	Operation PersonOperation
+	Actor     string
+	ChangedAt time.Time
+	Before    *Person
+	After     *Person
+}
+
+// HistoryPage is a keyset page request over revisions, newest first.
+type HistoryPage struct {
+	Limit int
+	After *PersonRevision
+}
+
+type PersonRevisionPage struct {
+	Revisions []*PersonRevision
+	Next      *PersonRevision
+}
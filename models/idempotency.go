@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response of a create sent with an
+// Idempotency-Key header. Status is zero while the create is in flight.
+// Keys of different actors never meet, even when they are equal.
+type IdempotencyKey struct {
+	Actor       string
+	Key         string
+	RequestHash string
+	Status      int
+	Location    string
+	ETag        string
+	ExpiresAt   time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// PersonRow is a single record of an imported file. Line points at the
+// record in the source, Err tells why the record can not be imported.
+type PersonRow struct {
+	Line   int
+	Person *Person
+	Err    error
+}
+
+// PersonSource streams records of an imported file. Next returns io.EOF
+// after the last record, any other error aborts the import.
+type PersonSource interface {
+	Next() (*PersonRow, error)
+}
+
+type ImportReport struct {
+	Imported int64
+	Rejected []*PersonRow
+	Duration time.Duration
+}
+
+func (r *ImportReport) RowsPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+
+	return float64(r.Imported) / r.Duration.Seconds()
+}
@@ -0,0 +1,22 @@
+package models
+
+// Page describes a keyset page request: at most Limit rows ordered by Sort
+// that follow After. Zero Limit means no limit, nil After means the first page.
+type Page struct {
+	Limit int
+	After *Person
+	Sort  []Sort
+}
+
+// Sort orders rows by a single field. Rows are always tie-broken by id.
+type Sort struct {
+	Field PersonField
+	Desc  bool
+}
+
+// PersonPage is a single page of persons. Next is the last person of the page
+// when more rows follow it, nil otherwise.
+type PersonPage struct {
+	Persons []*Person
+	Next    *Person
+}
@@ -1,6 +1,49 @@
 package models
 
+import "time"
+
 type Person struct {
 	Name, Address, Work string
 	Id, Age             int
+	// Version grows with every write. Writes that carry a non-zero Version
+	// apply only while it is still the current one.
+	Version              int
+	CreatedAt, UpdatedAt time.Time
+	// DeletedAt is set only for persons in the trash
+	DeletedAt time.Time
+}
+
+// PersonField names a Person field the way the API exposes it.
+type PersonField string
+
+const (
+	PersonFieldId      PersonField = "id"
+	PersonFieldName    PersonField = "name"
+	PersonFieldAddress PersonField = "address"
+	PersonFieldWork    PersonField = "work"
+	PersonFieldAge     PersonField = "age"
+)
+
+// PersonFields are the fields a client writes, id is not one of them.
+var PersonFields = []PersonField{PersonFieldName, PersonFieldAddress, PersonFieldWork, PersonFieldAge}
+
+// PersonMask is the set of fields an update writes, the others are kept.
+type PersonMask map[PersonField]bool
+
+func NewPersonMask(fields ...PersonField) PersonMask {
+	mask := make(PersonMask, len(fields))
+	for _, field := range fields {
+		mask[field] = true
+	}
+
+	return mask
+}
+
+// FullPersonMask covers every writable field, as create and replace do.
+func FullPersonMask() PersonMask {
+	return NewPersonMask(PersonFields...)
+}
+
+func (m PersonMask) Has(field PersonField) bool {
+	return m[field]
 }
@@ -0,0 +1,33 @@
+package models
+
+type SearchMode string
+
+const (
+	// SearchModeFullText matches words of name, address and work
+	SearchModeFullText SearchMode = "fulltext"
+	// SearchModeFuzzy matches name by trigram similarity, transliteration included
+	SearchModeFuzzy SearchMode = "fuzzy"
+)
+
+type PersonSearch struct {
+	Query     string
+	Mode      SearchMode
+	Threshold float32
+}
+
+// PersonHit is a person found by search together with its relevance.
+type PersonHit struct {
+	Person *Person
+	Rank   float32
+}
+
+// SearchPage is a keyset page request over hits ordered by descending rank.
+type SearchPage struct {
+	Limit int
+	After *PersonHit
+}
+
+type PersonHitPage struct {
+	Hits []*PersonHit
+	Next *PersonHit
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// PersonStat sums up the persons a filter matches, enough to tell whether
+// a listing of them has changed.
+type PersonStat struct {
+	Count        int64
+	LastModified time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// User is an account of the API. PasswordHash is the bcrypt hash, the
+// password itself is never kept.
+type User struct {
+	Id           int
+	Login        string
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+type UserCredentials struct {
+	Login    string
+	Password string
+}
+
+// UserTokens are what a login gives: a short lived access token for the
+// API and a refresh token that only gets new tokens.
+type UserTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
@@ -6,42 +6,63 @@ import (
 )
 
 var (
-	ErrNotFound           = errors.New("not found")
-	ErrNoContent          = errors.New("no content")
-	ErrUnauthorized       = errors.New("user unathorized")
-	ErrForbidden          = errors.New("user not an owner")
-	ErrInvalidContent     = errors.New("invalid content")
-	ErrLoginExists        = errors.New("login already exists")
-	ErrInvalidAccessToken = errors.New("invalid access token")
-	ErrInvalidPassword    = errors.New("invalid password")
+	ErrNotFound             = errors.New("not found")
+	ErrNoContent            = errors.New("no content")
+	ErrUnauthorized         = errors.New("user unathorized")
+	ErrForbidden            = errors.New("user not an owner")
+	ErrInvalidContent       = errors.New("invalid content")
+	ErrLoginExists          = errors.New("login already exists")
+	ErrInvalidAccessToken   = errors.New("invalid access token")
+	ErrInvalidPassword      = errors.New("invalid password")
+	ErrFailedDependency     = errors.New("failed dependency")
+	ErrConflict             = errors.New("conflict")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with another request")
 )
 
 func MatchHttpErr(err error) int {
-	if err == ErrNotFound {
+	if errors.Is(err, ErrNotFound) {
 		return http.StatusNotFound
 	}
 
-	if err == ErrNoContent {
+	if errors.Is(err, ErrNoContent) {
 		return http.StatusNoContent
 	}
 
-	if err == ErrInvalidContent {
+	if errors.Is(err, ErrInvalidContent) {
 		return http.StatusBadRequest
 	}
 
-	if err == ErrForbidden {
+	if errors.Is(err, ErrForbidden) {
 		return http.StatusForbidden
 	}
 
-	if err == ErrUnauthorized ||
-		err == ErrInvalidAccessToken ||
-		err == ErrInvalidPassword {
+	if errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrInvalidAccessToken) ||
+		errors.Is(err, ErrInvalidPassword) {
 		return http.StatusUnauthorized
 	}
 
-	if err == ErrLoginExists {
+	if errors.Is(err, ErrLoginExists) || errors.Is(err, ErrConflict) {
 		return http.StatusConflict
 	}
 
+	if errors.Is(err, ErrPreconditionFailed) {
+		return http.StatusPreconditionFailed
+	}
+
+	if errors.Is(err, ErrPreconditionRequired) {
+		return http.StatusPreconditionRequired
+	}
+
+	if errors.Is(err, ErrIdempotencyKeyReused) {
+		return http.StatusUnprocessableEntity
+	}
+
+	if errors.Is(err, ErrFailedDependency) {
+		return http.StatusFailedDependency
+	}
+
 	return http.StatusInternalServerError
 }
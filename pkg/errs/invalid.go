@@ -0,0 +1,126 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// InvalidError is ErrInvalidContent with details for the client: a message
+// and, when validation fails, a message per field.
+type InvalidError struct {
+	Message string
+	Fields  map[string]string
+}
+
+func Invalid(format string, args ...interface{}) error {
+	return &InvalidError{Message: fmt.Sprintf(format, args...)}
+}
+
+func InvalidFields(fields map[string]string) error {
+	return &InvalidError{Message: "validation failed", Fields: fields}
+}
+
+func (e *InvalidError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + " " + e.Fields[name]
+	}
+
+	return e.Message + ": " + strings.Join(parts, ", ")
+}
+
+func (e *InvalidError) Unwrap() error {
+	return ErrInvalidContent
+}
+
+func init() {
+	// validator reports fields by their json names, as clients know them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" || name == "" {
+				return field.Name
+			}
+
+			return name
+		})
+	}
+}
+
+// FromBinding turns an error of gin binding or validation into InvalidError.
+func FromBinding(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make(map[string]string, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields[fe.Field()] = fieldMessage(fe)
+		}
+
+		return InvalidFields(fields)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return InvalidFields(map[string]string{typeErr.Field: "must be " + jsonType(typeErr.Type)})
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return Invalid("malformed json body")
+	}
+
+	if errors.Is(err, io.EOF) {
+		return Invalid("body is empty")
+	}
+
+	return Invalid(err.Error())
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	default:
+		return "failed on " + fe.Tag()
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,126 @@
+package errs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	MIMEProblemJSON = "application/problem+json"
+
+	// ProblemTypeBase prefixes the type of every problem, the types are
+	// stable and clients may switch on them
+	ProblemTypeBase = "/problems/"
+
+	traceHeader    = "X-Request-Id"
+	traceparentKey = "traceparent"
+	ctxTraceKey    = "trace_id"
+)
+
+// Problem is the RFC 7807 problem details object.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	TraceId  string            `json:"trace_id,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+var problemTypes = []struct {
+	err  error
+	name string
+}{
+	{ErrNotFound, "not-found"},
+	{ErrInvalidContent, "invalid-content"},
+	{ErrForbidden, "forbidden"},
+	{ErrUnauthorized, "unauthorized"},
+	{ErrInvalidAccessToken, "invalid-access-token"},
+	{ErrInvalidPassword, "invalid-password"},
+	{ErrLoginExists, "login-exists"},
+	{ErrFailedDependency, "failed-dependency"},
+	{ErrConflict, "conflict"},
+	{ErrPreconditionFailed, "precondition-failed"},
+	{ErrPreconditionRequired, "precondition-required"},
+	{ErrIdempotencyKeyReused, "idempotency-key-reused"},
+}
+
+// ProblemType gives the problem type URI of the sentinel err wraps,
+// "about:blank" when there is none.
+func ProblemType(err error) string {
+	for _, t := range problemTypes {
+		if errors.Is(err, t.err) {
+			return ProblemTypeBase + t.name
+		}
+	}
+
+	return "about:blank"
+}
+
+func NewProblem(c *gin.Context, err error) *Problem {
+	status := MatchHttpErr(err)
+
+	problem := &Problem{
+		Type:     ProblemType(err),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: c.Request.URL.RequestURI(),
+		TraceId:  TraceId(c),
+	}
+
+	if status == http.StatusInternalServerError {
+		return problem
+	}
+
+	problem.Detail = err.Error()
+
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		problem.Detail = invalid.Message
+		problem.Errors = invalid.Fields
+	}
+
+	return problem
+}
+
+// TraceId is the id of the request trace: the trace id of a W3C traceparent
+// or the X-Request-Id the gateway sent, a new one otherwise. A new id is
+// sent back in X-Request-Id.
+func TraceId(c *gin.Context) string {
+	if id := c.GetString(ctxTraceKey); id != "" {
+		return id
+	}
+
+	id := traceparentId(c.GetHeader(traceparentKey))
+	if id == "" {
+		id = c.GetHeader(traceHeader)
+	}
+
+	if id == "" {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		id = hex.EncodeToString(b)
+
+		c.Header(traceHeader, id)
+	}
+
+	c.Set(ctxTraceKey, id)
+
+	return id
+}
+
+// traceparentId takes the trace id out of "version-traceid-parentid-flags".
+func traceparentId(traceparent string) string {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+
+	return parts[1]
+}
@@ -0,0 +1,57 @@
+package errs
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors"`
+}
+
+// Abort ends the request with the status MatchHttpErr gives for err and a
+// body the API contract promises for it, or a problem details object when
+// the client accepts application/problem+json. Internal errors are not
+// disclosed to the client, they are attached to the context for the logger
+// instead.
+func Abort(c *gin.Context, err error) {
+	status := MatchHttpErr(err)
+
+	if status == http.StatusNoContent {
+		c.AbortWithStatus(status)
+		return
+	}
+
+	if status == http.StatusInternalServerError {
+		c.Error(err)
+	}
+
+	if c.NegotiateFormat(binding.MIMEJSON, MIMEProblemJSON) == MIMEProblemJSON {
+		c.Header("Content-Type", MIMEProblemJSON)
+		c.Abort()
+		c.Render(status, render.JSON{Data: NewProblem(c, err)})
+		return
+	}
+
+	if status == http.StatusInternalServerError {
+		c.AbortWithStatusJSON(status, ErrorResponse{Message: http.StatusText(status)})
+		return
+	}
+
+	var invalid *InvalidError
+	if errors.As(err, &invalid) && len(invalid.Fields) != 0 {
+		c.AbortWithStatusJSON(status, ValidationErrorResponse{Message: invalid.Message, Errors: invalid.Fields})
+		return
+	}
+
+	c.AbortWithStatusJSON(status, ErrorResponse{Message: err.Error()})
+}
@@ -0,0 +1,123 @@
+package translit
+
+import (
+	"strings"
+	"unicode"
+)
+
+var toLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// longest sequences go first so that "shch" wins over "sh"
+var toCyrillic = []struct {
+	latin, cyrillic string
+}{
+	{"shch", "щ"},
+	{"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yu", "ю"}, {"ya", "я"}, {"yo", "ё"},
+	{"a", "а"}, {"b", "б"}, {"v", "в"}, {"g", "г"}, {"d", "д"}, {"e", "е"},
+	{"z", "з"}, {"i", "и"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"},
+	{"o", "о"}, {"p", "п"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"},
+	{"f", "ф"}, {"h", "х"}, {"c", "к"}, {"q", "к"}, {"w", "в"}, {"x", "кс"},
+	{"j", "й"},
+}
+
+// ToLatin transliterates Russian letters to Latin, other runes are kept.
+// The result is lower case.
+func ToLatin(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(s) {
+		if latin, ok := toLatin[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// ToCyrillic transliterates Latin letters to Russian, other runes are kept.
+// The result is lower case.
+func ToCyrillic(s string) string {
+	var b strings.Builder
+
+	s = strings.ToLower(s)
+	prev := ' '
+
+	for len(s) > 0 {
+		// "y" is "й" after a vowel ("dmitriy") and "ы" otherwise ("ryzhov")
+		if s[0] == 'y' && !hasPrefixAny(s, "yu", "ya", "yo") {
+			if strings.ContainsRune("аеёиоуыэюя", prev) {
+				b.WriteString("й")
+				prev = 'й'
+			} else {
+				b.WriteString("ы")
+				prev = 'ы'
+			}
+			s = s[1:]
+			continue
+		}
+
+		matched := false
+		for _, pair := range toCyrillic {
+			if strings.HasPrefix(s, pair.latin) {
+				b.WriteString(pair.cyrillic)
+				prev = []rune(pair.cyrillic)[0]
+				s = s[len(pair.latin):]
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			r := []rune(s)[0]
+			b.WriteRune(r)
+			prev = r
+			s = s[len(string(r)):]
+		}
+	}
+
+	return b.String()
+}
+
+// Variants returns the lower cased input along with its Latin and Cyrillic
+// transliterations, without duplicates.
+func Variants(s string) []string {
+	s = strings.ToLower(strings.TrimFunc(s, unicode.IsSpace))
+	res := []string{s}
+
+	for _, v := range []string{ToLatin(s), ToCyrillic(s)} {
+		if !contains(res, v) {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+func hasPrefixAny(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package translit_test
+
+import (
+	"bmstu-dips-lab1/pkg/translit"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToCyrillic(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest string
+		input    string
+		expected string
+	}{
+		{nameTest: "surname", input: "Efremov", expected: "ефремов"},
+		{nameTest: "longest_first", input: "Shchukin", expected: "щукин"},
+		{nameTest: "digraphs", input: "Zhukov Khabarov Tsoy Chekhov Shishkin", expected: "жуков хабаров цой чехов шишкин"},
+		{nameTest: "y_after_vowel", input: "Dmitriy", expected: "дмитрий"},
+		{nameTest: "y_after_consonant", input: "Ryzhov", expected: "рыжов"},
+		{nameTest: "iotated", input: "Yuriy Yakovlev Yolkin", expected: "юрий яковлев ёлкин"},
+		{nameTest: "other_runes", input: "Moscow, 7-ya", expected: "москов, 7-я"},
+		{nameTest: "cyrillic_kept", input: "Иванов", expected: "иванов"},
+		{nameTest: "empty", input: "", expected: ""},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, translit.ToCyrillic(testCase.input))
+		})
+	}
+}
+
+func TestToLatin(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest string
+		input    string
+		expected string
+	}{
+		{nameTest: "surname", input: "Ефремов", expected: "efremov"},
+		{nameTest: "digraphs", input: "Щукин Жуков Хабаров Цой Чехов", expected: "shchukin zhukov khabarov tsoy chekhov"},
+		{nameTest: "signs_dropped", input: "Подъячев Васильев", expected: "podyachev vasilev"},
+		{nameTest: "yo", input: "Ёлкин", expected: "elkin"},
+		{nameTest: "latin_kept", input: "Ivanov 7", expected: "ivanov 7"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, translit.ToLatin(testCase.input))
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest string
+		input    string
+		expected []string
+	}{
+		{nameTest: "latin", input: " Efremov ", expected: []string{"efremov", "ефремов"}},
+		{nameTest: "cyrillic", input: "Ефремов", expected: []string{"ефремов", "efremov"}},
+		{nameTest: "no_letters", input: "42", expected: []string{"42"}},
+		{nameTest: "blank", input: "  ", expected: []string{""}},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, translit.Variants(testCase.input))
+		})
+	}
+}
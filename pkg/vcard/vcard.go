@@ -0,0 +1,262 @@
+package vcard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	MIMEType = "text/vcard"
+	Version  = "4.0"
+
+	// lines are folded at 75 octets as RFC 6350 recommends
+	maxLineOctets = 75
+)
+
+// Property is a single content line. Value keeps components of structured
+// properties (N, ADR, ORG) split by ";", unescaped. Params are dropped.
+type Property struct {
+	Name  string
+	Value []string
+}
+
+// Card is a vCard as an ordered list of properties without BEGIN, END and VERSION.
+type Card []Property
+
+// Get returns components of the first property with the name, nil if there is none.
+func (c Card) Get(name string) []string {
+	for _, p := range c {
+		if strings.EqualFold(p.Name, name) {
+			return p.Value
+		}
+	}
+
+	return nil
+}
+
+// Text returns the first component of the property, "" if there is none.
+func (c Card) Text(name string) string {
+	value := c.Get(name)
+	if len(value) == 0 {
+		return ""
+	}
+
+	return value[0]
+}
+
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("vcard line %d: %s", e.Line, e.Msg)
+}
+
+type Encoder struct {
+	w *bufio.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+func (e *Encoder) Encode(card Card) error {
+	e.writeLine("BEGIN:VCARD")
+	e.writeLine("VERSION:" + Version)
+
+	for _, p := range card {
+		components := make([]string, len(p.Value))
+		for i, v := range p.Value {
+			components[i] = escape(v)
+		}
+
+		e.writeLine(strings.ToUpper(p.Name) + ":" + strings.Join(components, ";"))
+	}
+
+	e.writeLine("END:VCARD")
+
+	return e.w.Flush()
+}
+
+func (e *Encoder) writeLine(line string) {
+	for len(line) > maxLineOctets {
+		cut := maxLineOctets
+		// do not split a multi-byte rune
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+
+		e.w.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+
+	e.w.WriteString(line + "\r\n")
+}
+
+type Decoder struct {
+	r    *bufio.Reader
+	line int
+
+	// the line read ahead to check it for folding
+	pending     string
+	pendingLine int
+	hasPending  bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next card and the line its BEGIN is at. A malformed card
+// results in a *SyntaxError, the decoder then goes on with the next card.
+// io.EOF is returned when there are no more cards.
+func (d *Decoder) Decode() (Card, int, error) {
+	var start int
+
+	for {
+		line, n, err := d.readLine()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if strings.EqualFold(strings.TrimSpace(line), "BEGIN:VCARD") {
+			start = n
+			break
+		}
+
+		if strings.TrimSpace(line) != "" {
+			return nil, n, &SyntaxError{Line: n, Msg: "BEGIN:VCARD expected"}
+		}
+	}
+
+	card := make(Card, 0)
+	var syntaxErr *SyntaxError
+
+	for {
+		line, n, err := d.readLine()
+		if err == io.EOF {
+			return nil, start, &SyntaxError{Line: n, Msg: "END:VCARD expected"}
+		}
+
+		if err != nil {
+			return nil, start, err
+		}
+
+		if strings.EqualFold(strings.TrimSpace(line), "END:VCARD") {
+			break
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			if syntaxErr == nil {
+				syntaxErr = &SyntaxError{Line: n, Msg: "property without value"}
+			}
+			continue
+		}
+
+		// drop params and the group prefix: "item1.ADR;TYPE=home" is ADR
+		name := strings.SplitN(line[:i], ";", 2)[0]
+		if j := strings.LastIndexByte(name, '.'); j >= 0 {
+			name = name[j+1:]
+		}
+
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "VERSION" {
+			continue
+		}
+
+		card = append(card, Property{Name: name, Value: splitComponents(line[i+1:])})
+	}
+
+	if syntaxErr != nil {
+		return nil, start, syntaxErr
+	}
+
+	return card, start, nil
+}
+
+// readLine returns the next unfolded line and the number of its first physical line.
+func (d *Decoder) readLine() (string, int, error) {
+	line, n := d.pending, d.pendingLine
+
+	if !d.hasPending {
+		raw, err := d.readPhysical()
+		if err != nil {
+			return "", d.line, err
+		}
+
+		line, n = raw, d.line
+	}
+
+	d.hasPending = false
+
+	for {
+		next, err := d.readPhysical()
+		if err == io.EOF {
+			return line, n, nil
+		}
+
+		if err != nil {
+			return "", n, err
+		}
+
+		if next != "" && (next[0] == ' ' || next[0] == '\t') {
+			line += next[1:]
+			continue
+		}
+
+		d.pending, d.pendingLine, d.hasPending = next, d.line, true
+
+		return line, n, nil
+	}
+}
+
+func (d *Decoder) readPhysical() (string, error) {
+	raw, err := d.r.ReadString('\n')
+	if err == io.EOF && raw != "" {
+		err = nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	d.line++
+
+	return strings.TrimRight(raw, "\r\n"), nil
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func splitComponents(value string) []string {
+	res := make([]string, 0, 1)
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(value[i])
+			}
+		case value[i] == ';':
+			res = append(res, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+
+	return append(res, b.String())
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,194 @@
+package vcard_test
+
+import (
+	"bmstu-dips-lab1/pkg/vcard"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder_Encode(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest string
+		card     vcard.Card
+		expected string
+	}{
+		{
+			nameTest: "ok",
+			card: vcard.Card{
+				{Name: "fn", Value: []string{"Ivan Ivanov"}},
+				{Name: "ADR", Value: []string{"", "", "Lenina 1", "Moscow"}},
+			},
+			expected: "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Ivan Ivanov\r\nADR:;;Lenina 1;Moscow\r\nEND:VCARD\r\n",
+		},
+		{
+			nameTest: "escaped",
+			card: vcard.Card{
+				{Name: "NOTE", Value: []string{"a,b;c\\d\ne\r\nf"}},
+			},
+			expected: "BEGIN:VCARD\r\nVERSION:4.0\r\nNOTE:a\\,b\\;c\\\\d\\ne\\nf\r\nEND:VCARD\r\n",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := vcard.NewEncoder(&buf).Encode(testCase.card)
+
+			assert.Equal(t, nil, err)
+			assert.Equal(t, testCase.expected, buf.String())
+		})
+	}
+}
+
+func TestEncoder_EncodeFolded(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		nameTest string
+		value    string
+	}{
+		{nameTest: "ascii", value: strings.Repeat("abcdefghij", 20)},
+		{nameTest: "cyrillic", value: strings.Repeat("Ефремов ", 30)},
+		// the 75th octet falls in the middle of a rune
+		{nameTest: "cyrillic_odd", value: "x" + strings.Repeat("я", 100)},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := vcard.NewEncoder(&buf).Encode(vcard.Card{{Name: "FN", Value: []string{testCase.value}}})
+			assert.Equal(t, nil, err)
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+			assert.Greater(t, len(lines), 4)
+
+			for i, line := range lines {
+				assert.LessOrEqual(t, len(line), 75, "line %d", i)
+				assert.True(t, utf8.ValidString(line), "line %d", i)
+			}
+
+			card, _, err := vcard.NewDecoder(&buf).Decode()
+			assert.Equal(t, nil, err)
+			assert.Equal(t, testCase.value, card.Text("FN"))
+		})
+	}
+}
+
+func TestDecoder_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	cards := []vcard.Card{
+		{
+			{Name: "FN", Value: []string{"Иван Иванов"}},
+			{Name: "ADR", Value: []string{"", "", "ул. Ленина, д. 1; кв. 2", "Москва"}},
+			{Name: "NOTE", Value: []string{"line1\nline2 \\ end"}},
+		},
+		{
+			{Name: "FN", Value: []string{"Petr"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := vcard.NewEncoder(&buf)
+	for _, card := range cards {
+		assert.Equal(t, nil, enc.Encode(card))
+	}
+
+	dec := vcard.NewDecoder(&buf)
+	for _, expected := range cards {
+		got, _, err := dec.Decode()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, got)
+	}
+
+	_, _, err := dec.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	t.Parallel()
+
+	type result struct {
+		card vcard.Card
+		line int
+		err  bool
+	}
+
+	testTable := []struct {
+		nameTest string
+		input    string
+		expected []result
+	}{
+		{
+			nameTest: "unfolded",
+			input:    "BEGIN:VCARD\nVERSION:4.0\nFN:Ivan\n  Ivanov\nNOTE:a\r\n\tb\nEND:VCARD\n",
+			expected: []result{
+				{card: vcard.Card{{Name: "FN", Value: []string{"Ivan Ivanov"}}, {Name: "NOTE", Value: []string{"ab"}}}, line: 1},
+			},
+		},
+		{
+			nameTest: "group_and_params",
+			input:    "\nbegin:vcard\nitem1.ADR;TYPE=home:;;Lenina 1;Moscow\nfn;CHARSET=UTF-8:Ivan\nend:vcard",
+			expected: []result{
+				{card: vcard.Card{{Name: "ADR", Value: []string{"", "", "Lenina 1", "Moscow"}}, {Name: "FN", Value: []string{"Ivan"}}}, line: 2},
+			},
+		},
+		{
+			nameTest: "property_without_value",
+			input:    "BEGIN:VCARD\nFN Ivan\nEND:VCARD\nBEGIN:VCARD\nFN:Petr\nEND:VCARD\n",
+			expected: []result{
+				{line: 1, err: true},
+				{card: vcard.Card{{Name: "FN", Value: []string{"Petr"}}}, line: 4},
+			},
+		},
+		{
+			nameTest: "garbage_before_card",
+			input:    "hello\nBEGIN:VCARD\nFN:Petr\nEND:VCARD\n",
+			expected: []result{
+				{line: 1, err: true},
+				{card: vcard.Card{{Name: "FN", Value: []string{"Petr"}}}, line: 2},
+			},
+		},
+		{
+			nameTest: "no_end",
+			input:    "BEGIN:VCARD\nFN:Petr\n",
+			expected: []result{
+				{line: 1, err: true},
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.nameTest, func(t *testing.T) {
+			dec := vcard.NewDecoder(strings.NewReader(testCase.input))
+
+			for _, expected := range testCase.expected {
+				card, line, err := dec.Decode()
+
+				assert.Equal(t, expected.line, line)
+
+				if expected.err {
+					var syntaxErr *vcard.SyntaxError
+					assert.True(t, errors.As(err, &syntaxErr), "%v", err)
+					continue
+				}
+
+				assert.Equal(t, nil, err)
+				assert.Equal(t, expected.card, card)
+			}
+
+			_, _, err := dec.Decode()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
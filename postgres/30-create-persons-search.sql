@@ -0,0 +1,8 @@
+\c persons;
+
+ALTER TABLE persons_ ADD COLUMN IF NOT EXISTS search_ tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('simple', coalesce(name_, '') || ' ' || coalesce(address_, '') || ' ' || coalesce(work_, ''))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS persons_search_idx ON persons_ USING GIN (search_);
@@ -0,0 +1,3 @@
+\c persons;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
@@ -0,0 +1,3 @@
+\c persons;
+
+ALTER TABLE persons_ ADD COLUMN IF NOT EXISTS version_ INT NOT NULL DEFAULT 1;
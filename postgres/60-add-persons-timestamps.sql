@@ -0,0 +1,6 @@
+\c persons;
+
+ALTER TABLE persons_ ADD COLUMN IF NOT EXISTS created_at_ TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE persons_ ADD COLUMN IF NOT EXISTS updated_at_ TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS persons_updated_at_idx ON persons_ (updated_at_);
@@ -0,0 +1,15 @@
+\c persons;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys_ (
+    key_ VARCHAR(255) PRIMARY KEY,
+    request_hash_ CHAR(64) NOT NULL,
+    status_ INT NOT NULL DEFAULT 0,
+    location_ TEXT NOT NULL DEFAULT '',
+    expires_at_ TIMESTAMPTZ NOT NULL
+);
+
+-- expired keys are taken over by the next request with the same key,
+-- the index keeps "DELETE ... WHERE expires_at_ < now()" cheap
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys_ (expires_at_);
+
+GRANT ALL PRIVILEGES ON TABLE idempotency_keys_ TO program;
@@ -0,0 +1,5 @@
+\c persons;
+
+ALTER TABLE persons_ ADD COLUMN IF NOT EXISTS deleted_at_ TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS persons_deleted_at_idx ON persons_ (deleted_at_) WHERE deleted_at_ IS NOT NULL;
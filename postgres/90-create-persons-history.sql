@@ -0,0 +1,18 @@
+\c persons;
+
+-- person_id_ refers to no persons_ row on purpose, the history outlives
+-- the person
+CREATE TABLE IF NOT EXISTS persons_history_ (
+    id_ BIGSERIAL PRIMARY KEY,
+    person_id_ INT NOT NULL,
+    version_ INT NOT NULL,
+    operation_ VARCHAR(16) NOT NULL,
+    actor_ TEXT NOT NULL,
+    changed_at_ TIMESTAMPTZ NOT NULL DEFAULT now(),
+    after_ JSONB
+);
+
+CREATE INDEX IF NOT EXISTS persons_history_person_idx ON persons_history_ (person_id_, id_);
+
+GRANT ALL PRIVILEGES ON TABLE persons_history_ TO program;
+GRANT ALL PRIVILEGES ON SEQUENCE persons_history__id__seq TO program;
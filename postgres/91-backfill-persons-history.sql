@@ -0,0 +1,12 @@
+\c persons;
+
+-- point-in-time reads rebuild persons from their history, persons written
+-- before it was kept get a revision of their current state
+INSERT INTO persons_history_ (person_id_, version_, operation_, actor_, changed_at_, after_)
+SELECT id_, version_, 'create', 'anonymous', updated_at_,
+       jsonb_build_object('name', name_, 'address', address_, 'work', work_, 'age', age_)
+FROM persons_ p
+WHERE deleted_at_ IS NULL
+  AND NOT EXISTS (SELECT 1 FROM persons_history_ h WHERE h.person_id_ = p.id_);
+
+CREATE INDEX IF NOT EXISTS persons_history_changed_at_idx ON persons_history_ (changed_at_);
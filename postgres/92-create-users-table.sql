@@ -0,0 +1,11 @@
+\c persons;
+
+CREATE TABLE IF NOT EXISTS users_ (
+    id_ SERIAL PRIMARY KEY,
+    login_ VARCHAR(64) NOT NULL UNIQUE,
+    password_hash_ TEXT NOT NULL,
+    created_at_ TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+GRANT ALL PRIVILEGES ON TABLE users_ TO program;
+GRANT ALL PRIVILEGES ON SEQUENCE users__id__seq TO program;
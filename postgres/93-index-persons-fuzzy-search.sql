@@ -0,0 +1,5 @@
+\c persons;
+
+-- serves the name_ % ? filter of the fuzzy search, similarity() alone
+-- would scan the whole table
+CREATE INDEX IF NOT EXISTS persons_name_trgm_idx ON persons_ USING GIN (name_ gin_trgm_ops);
@@ -0,0 +1,13 @@
+\c persons;
+
+-- a key is unique per actor only, the same key from another actor is a
+-- request of its own rather than a replay. Keys taken before are left to
+-- the anonymous actor until they expire
+ALTER TABLE idempotency_keys_ ADD COLUMN IF NOT EXISTS actor_ TEXT NOT NULL DEFAULT 'anonymous';
+ALTER TABLE idempotency_keys_ ALTER COLUMN actor_ DROP DEFAULT;
+
+ALTER TABLE idempotency_keys_ DROP CONSTRAINT IF EXISTS idempotency_keys__pkey;
+ALTER TABLE idempotency_keys_ ADD PRIMARY KEY (actor_, key_);
+
+-- the ETag of the created person, sent again on a replay
+ALTER TABLE idempotency_keys_ ADD COLUMN IF NOT EXISTS etag_ TEXT NOT NULL DEFAULT '';